@@ -7,12 +7,17 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	// "net/url"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 
+	"github.com/JacobNewton007/busha-test/internals/cache"
+	custom_validator "github.com/JacobNewton007/busha-test/internals/custom_validator"
 	"github.com/JacobNewton007/busha-test/internals/data"
+	"github.com/JacobNewton007/busha-test/internals/swapi"
 	"github.com/go-redis/redis/v8"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -39,6 +44,37 @@ type config struct {
 		maxIdleConns int
 		maxIdleTime  string
 	}
+	swapi struct {
+		url          string
+		timeout      time.Duration
+		fixtures     string
+		snapshot     string
+		snapshotPoll time.Duration
+	}
+	admin struct {
+		token string
+	}
+	comments struct {
+		requireApproval bool
+	}
+	cache struct {
+		backend string
+		size    int
+		ttl     time.Duration
+		stale   time.Duration
+		lock    bool
+		warm    time.Duration
+	}
+	catalogue struct {
+		source       string
+		syncInterval time.Duration
+	}
+	rateLimit struct {
+		enabled  bool
+		defaults rateLimit
+		routes   []rateLimitRule
+	}
+	trustedProxies []*net.IPNet
 	// redis_url string
 }
 
@@ -50,10 +86,15 @@ type db_config struct {
 }
 
 type application struct {
-	config config
-	models data.Models
-	logger zap.SugaredLogger
-	client redis.Client
+	config    config
+	models    data.Models
+	logger    zap.SugaredLogger
+	cache     cache.Cache
+	loader    *cache.Loader
+	swapi     swapi.Client
+	validator *custom_validator.Validator
+	limiter   *rateLimiter
+	warmer    *cacheWarmer
 }
 
 var (
@@ -83,7 +124,73 @@ func RunApi() {
 		db: db_config,
 	}
 
+	cfg.swapi.url = os.Getenv("SWAPI_URL")
+	cfg.swapi.timeout, err = time.ParseDuration(os.Getenv("SWAPI_TIMEOUT"))
+	if err != nil {
+		cfg.swapi.timeout = 10 * time.Second
+	}
+	cfg.swapi.fixtures = os.Getenv("SWAPI_FIXTURES")
+	cfg.swapi.snapshotPoll, err = time.ParseDuration(getenv("SWAPI_SNAPSHOT_POLL", "2s"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.admin.token = os.Getenv("ADMIN_TOKEN")
+	cfg.comments.requireApproval, _ = strconv.ParseBool(os.Getenv("COMMENTS_REQUIRE_APPROVAL"))
+	cfg.trustedProxies, err = parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfg.cache.backend = getenv("CACHE_BACKEND", "redis")
+	cfg.cache.size, err = strconv.Atoi(os.Getenv("CACHE_SIZE"))
+	if err != nil {
+		cfg.cache.size = 1024
+	}
+	cfg.cache.ttl, err = time.ParseDuration(os.Getenv("CACHE_TTL"))
+	if err != nil {
+		cfg.cache.ttl = 2 * time.Hour
+	}
+	cfg.cache.stale, err = time.ParseDuration(os.Getenv("CACHE_STALE"))
+	if err != nil {
+		cfg.cache.stale = cfg.cache.ttl / 4
+	}
+	cfg.cache.lock, _ = strconv.ParseBool(os.Getenv("CACHE_LOCK"))
+	cfg.cache.warm, err = time.ParseDuration(getenv("CACHE_WARM_INTERVAL", "30m"))
+	if err != nil {
+		cfg.cache.warm = 30 * time.Minute
+	}
+
+	cfg.catalogue.source = getenv("CATALOGUE_SOURCE", catalogueSWAPI)
+	if cfg.catalogue.source != catalogueSWAPI && cfg.catalogue.source != cataloguePostgres {
+		log.Fatalf("invalid CATALOGUE_SOURCE %q, must be %s or %s", cfg.catalogue.source, catalogueSWAPI, cataloguePostgres)
+	}
+	// serving from Postgres needs the catalogue kept in sync; otherwise
+	// syncing is opt-in
+	syncInterval := "0"
+	if cfg.catalogue.source == cataloguePostgres {
+		syncInterval = "6h"
+	}
+	cfg.catalogue.syncInterval, err = time.ParseDuration(getenv("CATALOGUE_SYNC_INTERVAL", syncInterval))
+	if err != nil {
+		log.Fatalf("invalid CATALOGUE_SYNC_INTERVAL: %s", err)
+	}
+
+	cfg.rateLimit.enabled, err = strconv.ParseBool(os.Getenv("RATE_LIMIT_ENABLED"))
+	if err != nil {
+		cfg.rateLimit.enabled = true
+	}
+	cfg.rateLimit.defaults, err = parseRateLimit(getenv("RATE_LIMIT_DEFAULT", defaultRateLimit))
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.rateLimit.routes, err = parseRateLimitRules(getenv("RATE_LIMIT_ROUTES", defaultRateLimitRoutes))
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	displayVersion := flag.Bool("version", false, "Display version and exist")
+	syncCatalogue := flag.Bool("sync-catalogue", false, "Copy the SWAPI catalogue into Postgres and exit")
+	flag.StringVar(&cfg.swapi.snapshot, "swapi-snapshot", os.Getenv("SWAPI_SNAPSHOT"), "Serve SWAPI from the snapshot in this directory")
 
 	flag.Parse()
 
@@ -108,27 +215,84 @@ func RunApi() {
 		sugar.Fatal(err)
 	}
 
-	client, err := InitialRedis(cfg)
+	defer db.Close()
+	sugar.Infow("database connection pool established", "tag", "database-connection")
+
+	store, client, err := newCache(cfg, sugar)
 	if err != nil {
-		sugar.Error(err)
+		sugar.Fatal(err)
 	}
 
-	defer db.Close()
-	sugar.Infow("database connection pool established", "tag", "database-connection")
+	swapiClient, err := newSwapiClient(cfg)
+	if err != nil {
+		sugar.Fatal(err)
+	}
 
 	app := &application{
-		config: cfg,
-		models: data.CommentFactory(db),
-		logger: *sugar,
-		client: *client,
+		config:    cfg,
+		models:    data.CommentFactory(db),
+		logger:    *sugar,
+		cache:     store,
+		loader:    newLoader(cfg, store, client, sugar),
+		swapi:     swapiClient,
+		validator: custom_validator.New(),
+		limiter:   newRateLimiter(client),
+		warmer:    newCacheWarmer(cfg.cache.warm),
+	}
+
+	if *syncCatalogue {
+		err = app.syncCatalogue(context.Background())
+		if err != nil {
+			sugar.Fatal(err)
+		}
+		return
 	}
 
+	background, stopBackground := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		app.runWarmer(background, cfg.cache.warm)
+	}()
+	go func() {
+		defer wg.Done()
+		app.runCatalogueSync(background, cfg.catalogue.syncInterval)
+	}()
+	go func() {
+		defer wg.Done()
+		app.runSnapshotWatch(background, cfg.swapi.snapshotPoll)
+	}()
+
 	err = app.server()
+
+	// the server has drained its requests, now stop the background workers
+	stopBackground()
+	wg.Wait()
+
 	if err != nil {
 		sugar.Fatal(err)
 	}
 }
 
+// The budgets used when RATE_LIMIT_DEFAULT and RATE_LIMIT_ROUTES are unset.
+// Posting comments and walking every SWAPI character are the expensive
+// routes, so they get far less than the rest of the api.
+const (
+	defaultRateLimit       = "120/1m"
+	defaultRateLimitRoutes = "POST /v1/comments/:movie_name=10/1m,GET /v1/characters=30/1m,GET /v1/movies/:id/characters=30/1m"
+)
+
+// getenv returns the environment variable key, or fallback when it is unset.
+func getenv(key, fallback string) string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	return value
+}
+
 func openDB(cfg config) (*sql.DB, error) {
 	fmt.Println(cfg.db.dsn)
 	db, err := sql.Open("postgres", cfg.db.dsn)
@@ -159,6 +323,53 @@ func openDB(cfg config) (*sql.DB, error) {
 	return db, nil
 }
 
+// newSwapiClient serves SWAPI from a snapshot loaded into memory when
+// --swapi-snapshot is set, or from recorded fixtures when SWAPI_FIXTURES
+// points at a directory, so the api can run without network access.
+func newSwapiClient(cfg config) (swapi.Client, error) {
+	if cfg.swapi.snapshot != "" {
+		return swapi.NewSnapshot(cfg.swapi.snapshot)
+	}
+	if cfg.swapi.fixtures != "" {
+		return swapi.NewFake(cfg.swapi.fixtures), nil
+	}
+	return swapi.New(cfg.swapi.url, cfg.swapi.timeout), nil
+}
+
+// newCache returns the cache selected by CACHE_BACKEND, along with the
+// redis client behind it, if any. When redis can't be reached the api
+// carries on with the in-memory cache rather than refusing to start.
+func newCache(cfg config, logger *zap.SugaredLogger) (cache.Cache, *redis.Client, error) {
+	switch cfg.cache.backend {
+	case "memory":
+		return cache.NewLRU(cfg.cache.size), nil, nil
+	case "redis":
+		client, err := InitialRedis(cfg)
+		if err != nil {
+			logger.Warnw("redis unavailable, falling back to the in-memory cache", "tag", "cache", "error", err)
+			return cache.NewLRU(cfg.cache.size), nil, nil
+		}
+		return cache.NewRedis(client), client, nil
+	default:
+		return nil, nil, fmt.Errorf("invalid CACHE_BACKEND %q, must be redis or memory", cfg.cache.backend)
+	}
+}
+
+// newLoader wraps store in the stampede-protected loader the handlers cache
+// SWAPI data through. The distributed lock needs redis, so CACHE_LOCK is
+// ignored when running on the in-memory cache.
+func newLoader(cfg config, store cache.Cache, client *redis.Client, logger *zap.SugaredLogger) *cache.Loader {
+	loader := cache.NewLoader(store, cfg.cache.ttl)
+	loader.Stale = cfg.cache.stale
+	if cfg.cache.lock && client != nil {
+		loader.Lock = cache.NewRedisLocker(client)
+	}
+	loader.OnError = func(key string, err error) {
+		logger.Errorw(err.Error(), "tag", "cache", "key", key)
+	}
+	return loader
+}
+
 func InitialRedis(cfg config) (*redis.Client, error) {
 	if os.Getenv("APP_ENV") != "PRODUCTION" {
 		client := redis.NewClient(&redis.Options{
@@ -168,7 +379,8 @@ func InitialRedis(cfg config) (*redis.Client, error) {
 		})
 		err := client.Ping(Ctx).Err()
 		if err != nil {
-			log.Fatalf("Failed to connect to redis: %s", err.Error())
+			client.Close()
+			return nil, fmt.Errorf("failed to connect to redis: %w", err)
 		}
 		return client, nil
 	} else {
@@ -182,12 +394,13 @@ func InitialRedis(cfg config) (*redis.Client, error) {
 		// }
 		options, err := redis.ParseURL(redis_url)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 		client := redis.NewClient(options)
 		err = client.Ping(Ctx).Err()
 		if err != nil {
-			log.Fatalf("Failed to connect to redis: %s", err.Error())
+			client.Close()
+			return nil, fmt.Errorf("failed to connect to redis: %w", err)
 		}
 		return client, nil
 	}
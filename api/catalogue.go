@@ -0,0 +1,260 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/JacobNewton007/busha-test/internals/data"
+	"github.com/JacobNewton007/busha-test/internals/swapi"
+)
+
+// Where /v1/movies and /v1/characters read films and people from, set by
+// CATALOGUE_SOURCE.
+const (
+	catalogueSWAPI    = "swapi"
+	cataloguePostgres = "postgres"
+)
+
+func (app *application) fromPostgres() bool {
+	return app.config.catalogue.source == cataloguePostgres
+}
+
+// syncCatalogue copies every SWAPI film, person and planet into Postgres.
+// Rows are upserted by SWAPI id, planets first so people can reference
+// their homeworld and films their cast.
+func (app *application) syncCatalogue(ctx context.Context) error {
+	start := time.Now()
+
+	films, err := app.swapi.Films(ctx)
+	if err != nil {
+		return err
+	}
+
+	people, err := swapi.AllPeople(ctx, app.swapi, swapiPageWorkers)
+	if err != nil {
+		return err
+	}
+
+	planets, err := swapi.AllPlanets(ctx, app.swapi, swapiPageWorkers)
+	if err != nil {
+		return err
+	}
+
+	planetRows := make([]*data.Planet, 0, len(planets))
+	planetIDs := map[int]bool{}
+	for _, planet := range planets {
+		id, err := swapi.ResourceID(planet.URL)
+		if err != nil {
+			return err
+		}
+		planetIDs[id] = true
+
+		planetRows = append(planetRows, &data.Planet{
+			ID:         id,
+			Name:       planet.Name,
+			Climate:    planet.Climate,
+			Terrain:    planet.Terrain,
+			Population: planet.Population,
+			URL:        planet.URL,
+		})
+	}
+
+	personRows := make([]*data.Person, 0, len(people))
+	for _, person := range people {
+		id, err := swapi.ResourceID(person.URL)
+		if err != nil {
+			return err
+		}
+
+		row := &data.Person{
+			ID:             id,
+			Name:           person.Name,
+			Height:         person.Height,
+			Mass:           person.Mass,
+			HairColor:      person.HairColor,
+			SkinColor:      person.SkinColor,
+			EyeColor:       person.EyeColor,
+			BirthYear:      person.BirthYear,
+			Gender:         person.Gender,
+			HeightValue:    optionalNumber(parseNumber(person.Height)),
+			MassValue:      optionalNumber(parseNumber(person.Mass)),
+			BirthYearValue: optionalNumber(parseBirthYear(person.BirthYear)),
+			URL:            person.URL,
+		}
+
+		if homeworld, err := swapi.ResourceID(person.Homeworld); err == nil && planetIDs[homeworld] {
+			row.HomeworldID = &homeworld
+		}
+
+		personRows = append(personRows, row)
+	}
+
+	filmRows := make([]*data.Film, 0, len(films))
+	for _, film := range films {
+		id, err := swapi.ResourceID(film.URL)
+		if err != nil {
+			return err
+		}
+
+		filmRows = append(filmRows, &data.Film{
+			ID:           id,
+			EpisodeID:    film.EpisodeID,
+			Title:        film.Title,
+			Slug:         slugify(film.Title),
+			OpeningCrawl: film.OpeningCrawl,
+			Director:     film.Director,
+			Producer:     film.Producer,
+			ReleaseDate:  film.ReleaseDate,
+			URL:          film.URL,
+			Starships:    film.Starships,
+			Vehicles:     film.Vehicles,
+			Species:      film.Species,
+			PersonIDs:    resourceIDs(film.Characters),
+			PlanetIDs:    resourceIDs(film.Planets),
+		})
+	}
+
+	err = app.models.Planets.Upsert(planetRows)
+	if err != nil {
+		return err
+	}
+
+	err = app.models.People.Upsert(personRows)
+	if err != nil {
+		return err
+	}
+
+	err = app.models.Films.Upsert(filmRows)
+	if err != nil {
+		return err
+	}
+
+	// the movie list is built from the catalogue when serving from Postgres
+	if app.fromPostgres() {
+		app.cache.Delete(ctx, "movies")
+	}
+
+	app.logger.Infow("catalogue synced", "tag", "catalogue-sync", "films", len(filmRows), "people", len(personRows), "planets", len(planetRows), "duration", time.Since(start).String())
+
+	return nil
+}
+
+func optionalNumber(x float64, ok bool) *float64 {
+	if !ok {
+		return nil
+	}
+	return &x
+}
+
+// resourceIDs returns the SWAPI ids of urls, skipping any without one.
+func resourceIDs(urls []string) []int {
+	ids := make([]int, 0, len(urls))
+	for _, resourceURL := range urls {
+		if id, err := swapi.ResourceID(resourceURL); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// runCatalogueSync syncs the catalogue straight away and then every
+// interval until ctx is cancelled.
+func (app *application) runCatalogueSync(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	app.logger.Infow("starting catalogue sync", "tag", "catalogue-sync", "interval", interval.String())
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := app.syncCatalogue(ctx)
+		if err != nil {
+			app.logger.Errorw("catalogue sync failed", "tag", "catalogue-sync", "error", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			app.logger.Infow("stopped catalogue sync", "tag", "catalogue-sync")
+			return
+		}
+	}
+}
+
+// catalogueFilms lists the films from the configured catalogue source.
+func (app *application) catalogueFilms(ctx context.Context) ([]swapi.Film, error) {
+	if !app.fromPostgres() {
+		return app.swapi.Films(ctx)
+	}
+
+	rows, err := app.models.Films.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	films := make([]swapi.Film, 0, len(rows))
+	for _, row := range rows {
+		films = append(films, swapi.Film{
+			Title:        row.Title,
+			EpisodeID:    row.EpisodeID,
+			OpeningCrawl: row.OpeningCrawl,
+			Director:     row.Director,
+			Producer:     row.Producer,
+			ReleaseDate:  row.ReleaseDate,
+			Characters:   row.Characters,
+			Planets:      row.Planets,
+			Starships:    row.Starships,
+			Vehicles:     row.Vehicles,
+			Species:      row.Species,
+			URL:          row.URL,
+		})
+	}
+
+	return films, nil
+}
+
+// writeCatalogueCharacters answers a character query from Postgres, where
+// the filtering, sorting, paging and height summary all happen in SQL. A
+// filmID other than zero limits the characters to that film's cast, and
+// links, when not nil, is added to the response.
+func (app *application) writeCatalogueCharacters(w http.ResponseWriter, r *http.Request, q characterQuery, filmID int, links interface{}) error {
+	filters := data.PeopleFilters{FilmID: filmID, Offset: q.page.Offset, Limit: q.page.PageSize}
+
+	for _, key := range q.sortKeys {
+		filters.Sort = append(filters.Sort, data.PeopleSort{Field: key.field, Desc: key.desc})
+	}
+
+	for _, filter := range q.filters {
+		if filter.op == "in" {
+			filters.Matches = append(filters.Matches, data.PeopleMatch{Field: filter.field, Values: filter.values})
+			continue
+		}
+		filters.Ranges = append(filters.Ranges, data.PeopleRange{Field: filter.field, Op: filter.op, Value: filter.number})
+	}
+
+	people, summary, err := app.models.People.GetAll(filters)
+	if err != nil {
+		return err
+	}
+
+	results := make([]Result, 0, len(people))
+	for _, person := range people {
+		results = append(results, Result{
+			Name:      person.Name,
+			Height:    person.Height,
+			Mass:      person.Mass,
+			Gender:    person.Gender,
+			BirthYear: person.BirthYear,
+			EyeColor:  person.EyeColor,
+			HairColor: person.HairColor,
+			SkinColor: person.SkinColor,
+			Homeworld: person.Homeworld,
+		})
+	}
+
+	return app.writeCharacterPage(w, r, q.page, results, newCharacterMetadata(summary, q.units), links)
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestShowMovieHandlerFromCatalogue(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.catalogue.source = cataloguePostgres
+	mock := mockModels(t, app)
+
+	starships := []string{"https://swapi.dev/api/starships/2/", "https://swapi.dev/api/starships/3/"}
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM films f")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "episode_id", "title", "slug", "opening_crawl", "director", "producer", "release_date", "url", "starships", "vehicles", "species", "characters", "planets"}).
+			AddRow(1, 4, "A New Hope", "a-new-hope", "", "George Lucas", "", "1977-05-25", "https://swapi.dev/api/films/1/",
+				"{https://swapi.dev/api/starships/2/,https://swapi.dev/api/starships/3/}", "{}", "{}", "{}", "{}"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*)")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(regexp.QuoteMeta("ORDER BY rank DESC, created_at DESC, id DESC")).
+		WillReturnRows(commentRows())
+	mock.ExpectQuery(regexp.QuoteMeta("FROM unnest($1::bigint[], $2::text[])")).
+		WillReturnRows(sqlmock.NewRows([]string{"episode_id", "count"}))
+
+	status, body := get(t, app.routes(), "/v1/movies/a-new-hope")
+	if status != http.StatusOK {
+		t.Fatalf("status %d, want %d: %s", status, http.StatusOK, body["error"])
+	}
+
+	var movie struct {
+		Starships []string `json:"starships"`
+		Vehicles  []string `json:"vehicles"`
+	}
+	if err := json.Unmarshal(body["movie"], &movie); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(movie.Starships, starships) || movie.Vehicles == nil || len(movie.Vehicles) != 0 {
+		t.Errorf("starships %v and vehicles %v, want the catalogue's urls", movie.Starships, movie.Vehicles)
+	}
+}
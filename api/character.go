@@ -1,163 +1,281 @@
 package api
 
 import (
-	"encoding/json"
+	"context"
 	"net/http"
-	"time"
+	"net/url"
+	"strings"
+
+	"github.com/JacobNewton007/busha-test/internals/swapi"
 )
 
 type Result struct {
-	Name   string `json:"name"`
-	Height string `json:"height"`
-	Gender string `json:"gender"`
+	Name      string `json:"name"`
+	Height    string `json:"height"`
+	Mass      string `json:"mass"`
+	Gender    string `json:"gender"`
+	BirthYear string `json:"birth_year"`
+	EyeColor  string `json:"eye_color"`
+	HairColor string `json:"hair_color"`
+	SkinColor string `json:"skin_color"`
+	Homeworld string `json:"homeworld"`
 }
 
+// Metadata describes the whole filtered result set; the page fields
+// locate the returned characters within it.
+// Feets and Inches break the total height down into feet and inches.
 type Metadata struct {
-	Feets  float64 `json:"feets"`
-	Inches float64 `json:"inches"`
-	Count  int     `json:"count"`
+	Feets     int         `json:"feets"`
+	Inches    float64     `json:"inches"`
+	Count     int         `json:"count"`
+	Height    HeightStats `json:"height"`
+	Page      int         `json:"page"`
+	PageSize  int         `json:"page_size"`
+	PageCount int         `json:"page_count"`
+	Next      string      `json:"next,omitempty"`
+	Prev      string      `json:"prev,omitempty"`
 }
 
 type Character struct {
 	Results []Result `json:"results"`
 }
 
-var character Character
-var metadata Metadata
+// newCharacter converts SWAPI people into characters, resolving each
+// homeworld url to the planet's name through planets.
+func newCharacter(people []swapi.Person, planets map[string]string) Character {
+	character := Character{}
+	for _, person := range people {
+		character.Results = append(character.Results, Result{
+			Name:      person.Name,
+			Height:    person.Height,
+			Mass:      person.Mass,
+			Gender:    person.Gender,
+			BirthYear: person.BirthYear,
+			EyeColor:  person.EyeColor,
+			HairColor: person.HairColor,
+			SkinColor: person.SkinColor,
+			Homeworld: planets[person.Homeworld],
+		})
+	}
+	return character
+}
 
-func (app *application) GetCharactersHandler(w http.ResponseWriter, r *http.Request) {
-	qs := r.URL.Query()
+// swapiPageWorkers bounds how many SWAPI list pages are fetched at once.
+const swapiPageWorkers = 4
 
-	sort_value := app.readString(qs, "sort", "")
+// allCharacters returns every SWAPI character, cached as a single dataset.
+func (app *application) allCharacters(ctx context.Context) (Character, error) {
+	character := Character{}
+	err := app.cacheAside(ctx, "characters", &character, func(ctx context.Context) (interface{}, error) {
+		return app.loadCharacters(ctx)
+	})
+	return character, err
+}
 
-	rdb := app.client
-	charactersSortValue, _ := rdb.Get(Ctx, string(sort_value)).Result()
+// loadCharacters walks every SWAPI people and planet page to build the
+// full character list.
+func (app *application) loadCharacters(ctx context.Context) (Character, error) {
+	people, err := swapi.AllPeople(ctx, app.swapi, swapiPageWorkers)
+	if err != nil {
+		return Character{}, err
+	}
 
-	filter_value := app.readString(qs, "gender", "")
+	planets, err := swapi.AllPlanets(ctx, app.swapi, swapiPageWorkers)
+	if err != nil {
+		return Character{}, err
+	}
 
-	charactersFilterValue, _ := rdb.Get(Ctx, filter_value).Result()
+	planetNames := make(map[string]string, len(planets))
+	for _, planet := range planets {
+		planetNames[planet.URL] = planet.Name
+	}
 
-	if charactersFilterValue != "" || charactersSortValue != "" {
+	return newCharacter(people, planetNames), nil
+}
 
-		if charactersFilterValue != "" {
-			character := Character{}
-			json.Unmarshal([]byte(charactersFilterValue), &character)
+// writeCharacters responds with the requested page of a sorted or filtered
+// character set. The counts and height statistics in the metadata cover the
+// whole set, not just the page. links, when not nil, is added to the
+// response alongside the metadata.
+func (app *application) writeCharacters(w http.ResponseWriter, r *http.Request, page pagination, character Character, units string, links interface{}) error {
+	metadata := app.createCharacterMetaData(character, units)
 
-			totalHeight := app.findTotalHeight(0, character)
-			metadata = app.createCharacterMetaData(metadata, totalHeight, character)
-			err := app.writeJSON(w, http.StatusOK, envelope{"character": character.Results, "metadata": metadata, "message": "fetch characters successfully", "status": "success"}, nil)
-			if err != nil {
-				app.serverErrorResponse(w, r, err)
-				return
-			}
+	start, end := page.window(len(character.Results))
 
-		} else if charactersSortValue != "" {
-			character := Character{}
+	return app.writeCharacterPage(w, r, page, character.Results[start:end], metadata, links)
+}
 
-			json.Unmarshal([]byte(charactersSortValue), &character)
-			totalHeight := app.findTotalHeight(0, character)
-			metadata = app.createCharacterMetaData(metadata, totalHeight, character)
-			err := app.writeJSON(w, http.StatusOK, envelope{"character": character.Results, "metadata": metadata, "message": "fetch characters successfully", "status": "success"}, nil)
-			if err != nil {
-				app.serverErrorResponse(w, r, err)
-				return
-			}
+// writeCharacterPage responds with results, the requested page of a
+// character set described by metadata, adding the page fields and links to
+// the neighbouring pages.
+func (app *application) writeCharacterPage(w http.ResponseWriter, r *http.Request, page pagination, results []Result, metadata Metadata, links interface{}) error {
+	total := metadata.Count
+	start, end := page.window(total)
+
+	metadata.Page = page.page()
+	metadata.PageSize = page.PageSize
+	metadata.PageCount = pageCount(total, page.PageSize)
+	if end < total {
+		metadata.Next = cursorLink(r, page, end)
+	}
+	if start > 0 {
+		prev := start - page.PageSize
+		if prev < 0 {
+			prev = 0
+		}
+		metadata.Prev = cursorLink(r, page, prev)
+	}
 
-		} else {
-			characters, _ := rdb.Get(Ctx, sort_value).Result()
-			character := Character{}
+	if results == nil {
+		results = []Result{}
+	}
 
-			json.Unmarshal([]byte(characters), &character)
+	env := envelope{"character": results, "metadata": metadata, "message": "fetch characters successfully", "status": "success"}
+	if links != nil {
+		env["links"] = links
+	}
 
-			totalHeight := app.findTotalHeight(0, character)
-			metadata = app.createCharacterMetaData(metadata, totalHeight, character)
-			err := app.writeJSON(w, http.StatusOK, envelope{"character": character.Results, "metadata": metadata, "message": "fetch characters successfully", "status": "success"}, nil)
-			if err != nil {
-				app.serverErrorResponse(w, r, err)
-				return
-			}
+	return app.writeJSON(w, http.StatusOK, env, nil)
+}
 
-		}
+// characterQuery holds the sort, filter, pagination and units parameters
+// shared by every character listing.
+type characterQuery struct {
+	page     pagination
+	units    string
+	sortKeys []sortKey
+	filters  []characterFilter
+}
 
-	} else {
-		const url = "https://swapi.dev/api/people"
-		body, err := app.fetchUrl(url)
-		if err != nil {
-			app.serverErrorResponse(w, r, err)
-			return
-		}
+func (app *application) readCharacterQuery(qs url.Values) (characterQuery, error) {
+	var q characterQuery
+	var err error
 
-		json.Unmarshal(body, &character)
-		if filter_value == "" && sort_value != "" {
+	// parameter names are case-insensitive, as they are in the cache key
+	qs = normalizeQuery(qs)
 
-			sorted_character := app.sortBy(sort_value, character)
+	q.page, err = app.readPagination(qs)
+	if err != nil {
+		return q, err
+	}
 
-			data, err := json.Marshal(sorted_character)
-			if err != nil {
-				app.serverErrorResponse(w, r, err)
-				return
-			}
+	q.units, err = app.readUnits(qs)
+	if err != nil {
+		return q, err
+	}
 
-			err = app.client.Set(Ctx, sort_value, data, time.Hour*2).Err()
-			if err != nil {
-				app.serverErrorResponse(w, r, err)
-				return
-			}
+	sort_value := strings.ToLower(strings.TrimSpace(app.readString(qs, "sort", "")))
 
-			totalHeight := app.findTotalHeight(0, character)
-			metadata = app.createCharacterMetaData(metadata, totalHeight, sorted_character)
-			err = app.writeJSON(w, http.StatusOK, envelope{"character": sorted_character.Results, "metadata": metadata, "message": "fetch characters successfully", "status": "success"}, nil)
-			if err != nil {
-				app.serverErrorResponse(w, r, err)
-				return
-			}
+	q.sortKeys, err = app.parseSort(sort_value)
+	if err != nil {
+		return q, err
+	}
 
-		} else if filter_value != "" && sort_value == "" {
-			character, totalHeight := app.filterBy(filter_value, character)
+	q.filters, err = app.parseFilters(qs)
+	if err != nil {
+		return q, err
+	}
 
-			data, err := json.Marshal(character)
-			if err != nil {
-				app.serverErrorResponse(w, r, err)
-				return
-			}
+	return q, nil
+}
 
-			err = app.client.Set(Ctx, filter_value, data, time.Hour*2).Err()
-			if err != nil {
-				app.serverErrorResponse(w, r, err)
-				return
-			}
+// apply filters first so the sort only orders the characters we return.
+func (q characterQuery) apply(app *application, character Character) Character {
+	if len(q.filters) > 0 {
+		character = app.filterBy(q.filters, character)
+	}
+	if len(q.sortKeys) > 0 {
+		character = app.sortBy(q.sortKeys, character)
+	}
+	return character
+}
 
-			metadata = app.createCharacterMetaData(metadata, totalHeight, character)
-			err = app.writeJSON(w, http.StatusOK, envelope{"character": character, "metadata": metadata, "message": "fetch characters successfully", "status": "success"}, nil)
-			if err != nil {
-				app.serverErrorResponse(w, r, err)
-				return
-			}
+func (app *application) GetCharactersHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
 
-		} else {
-			sorted_character := app.sortBy(sort_value, character)
-			data, err := json.Marshal(sorted_character)
-			if err != nil {
-				app.serverErrorResponse(w, r, err)
-				return
-			}
+	q, err := app.readCharacterQuery(qs)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
 
-			err = app.client.Set(Ctx, "character", data, time.Hour*2).Err()
-			if err != nil {
-				app.serverErrorResponse(w, r, err)
-				return
-			}
+	if app.fromPostgres() {
+		err = app.writeCatalogueCharacters(w, r, q, 0, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
 
-			totalHeight := app.findTotalHeight(0, character)
-			metadata = app.createCharacterMetaData(metadata, totalHeight, character)
-			err = app.writeJSON(w, http.StatusOK, envelope{"character": sorted_character, "metadata": metadata, "message": "fetch characters successfully", "status": "success"}, nil)
-			if err != nil {
-				app.serverErrorResponse(w, r, err)
-				return
-			}
+	character, err := app.queryCharacters(r.Context(), "characters", qs, app.allCharacters, q)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeCharacters(w, r, q.page, character, q.units, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// queryCharacters applies q to the character set returned by load and
+// caches the result under prefix plus a key derived from the request's
+// query parameters, so every distinct combination of sort and filters gets
+// its own entry.
+func (app *application) queryCharacters(ctx context.Context, prefix string, qs url.Values, load func(context.Context) (Character, error), q characterQuery) (Character, error) {
+	key := charactersCacheKey(qs)
+	if key == "" {
+		return load(ctx)
+	}
+
+	character := Character{}
+	err := app.cacheAside(ctx, prefix+":"+key, &character, func(ctx context.Context) (interface{}, error) {
+		all, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return q.apply(app, all), nil
+	})
+
+	return character, err
+}
 
+// charactersCacheKey normalizes every query parameter that shapes the
+// result set (pagination only slices it) into a stable cache key. It
+// returns "" when the request asks for the unfiltered, unsorted set.
+func charactersCacheKey(qs url.Values) string {
+	normalized := url.Values{}
+	for key, values := range normalizeQuery(qs) {
+		switch key {
+		case "page", "page_size", "cursor", "units":
+			continue
 		}
+		for _, value := range values {
+			value = strings.ToLower(strings.TrimSpace(value))
+			if value != "" {
+				normalized.Add(key, value)
+			}
+		}
+	}
+
+	if len(normalized) == 0 {
+		return ""
 	}
-	// sort by params and filter by gender
 
-}
\ No newline at end of file
+	// Encode sorts by key, which makes the key independent of parameter order.
+	return normalized.Encode()
+}
+
+// normalizeQuery lowercases and trims the parameter names in qs, merging
+// the values of names that differ only in case, so "?Gender=male" reads
+// the same as "?gender=male".
+func normalizeQuery(qs url.Values) url.Values {
+	normalized := make(url.Values, len(qs))
+	for key, values := range qs {
+		key = strings.ToLower(strings.TrimSpace(key))
+		normalized[key] = append(normalized[key], values...)
+	}
+	return normalized
+}
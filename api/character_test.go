@@ -0,0 +1,182 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestReadCharacterQuery(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		query   string
+		want    characterQuery
+		wantErr bool
+	}{
+		{query: "", want: characterQuery{page: pagination{PageSize: defaultPageSize}, units: "metric"}},
+		{
+			query: "Sort=-Height&UNITS=Imperial&Page_Size=5&page=2&gender=male",
+			want: characterQuery{
+				page:     pagination{Offset: 5, PageSize: 5},
+				units:    "imperial",
+				sortKeys: []sortKey{{field: "height", desc: true}},
+				filters:  []characterFilter{{field: "gender", op: "in", values: []string{"male"}}},
+			},
+		},
+		{query: "units=furlongs", wantErr: true},
+		{query: "sort=age", wantErr: true},
+		{query: "page_size=1000", wantErr: true},
+		{query: "eye=blue", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		qs := mustParseQuery(t, tt.query)
+
+		got, err := app.readCharacterQuery(qs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("readCharacterQuery(%q) error = %v, want error %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("readCharacterQuery(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestGetCharactersHandler(t *testing.T) {
+	app := newTestApplication(t)
+	routes := app.routes()
+
+	tests := []struct {
+		target    string
+		status    int
+		count     int
+		names     []string
+		pageCount int
+		next      bool
+	}{
+		{target: "/v1/characters?page_size=5", status: http.StatusOK, count: 27, pageCount: 6, next: true,
+			names: []string{"Luke Skywalker", "C-3PO", "R2-D2", "Darth Vader", "Leia Organa"}},
+		{target: "/v1/characters?sort=-height&page_size=3", status: http.StatusOK, count: 27, pageCount: 9, next: true,
+			names: []string{"Chewbacca", "Darth Vader", "IG-88"}},
+		{target: "/v1/characters?gender=female&sort=name", status: http.StatusOK, count: 2, pageCount: 1,
+			names: []string{"Beru Whitesun lars", "Leia Organa"}},
+		{target: "/v1/characters?Gender=FEMALE&Sort=name", status: http.StatusOK, count: 2, pageCount: 1,
+			names: []string{"Beru Whitesun lars", "Leia Organa"}},
+		{target: "/v1/characters?height_gte=200&sort=name", status: http.StatusOK, count: 3, pageCount: 1,
+			names: []string{"Chewbacca", "Darth Vader", "IG-88"}},
+		{target: "/v1/characters?eye=blue", status: http.StatusBadRequest},
+		{target: "/v1/characters?page=0", status: http.StatusBadRequest},
+		{target: "/v1/characters?cursor=bogus", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			status, body := get(t, routes, tt.target)
+			if status != tt.status {
+				t.Fatalf("status %d, want %d: %s", status, tt.status, body["error"])
+			}
+			if status != http.StatusOK {
+				return
+			}
+
+			characters, metadata := decodeCharacters(t, body)
+			if metadata.Count != tt.count || metadata.PageCount != tt.pageCount || (metadata.Next != "") != tt.next {
+				t.Errorf("metadata count %d, page count %d, next %q", metadata.Count, metadata.PageCount, metadata.Next)
+			}
+			if !reflect.DeepEqual(characters, tt.names) {
+				t.Errorf("characters %q, want %q", characters, tt.names)
+			}
+		})
+	}
+}
+
+func TestGetCharactersHandlerFollowsCursors(t *testing.T) {
+	app := newTestApplication(t)
+	routes := app.routes()
+
+	var names []string
+	target := "/v1/characters?page_size=10&sort=name"
+	for target != "" {
+		status, body := get(t, routes, target)
+		if status != http.StatusOK {
+			t.Fatalf("GET %s: status %d", target, status)
+		}
+
+		page, metadata := decodeCharacters(t, body)
+		names = append(names, page...)
+		target = metadata.Next
+	}
+
+	if len(names) != 27 {
+		t.Fatalf("walked %d characters, want 27", len(names))
+	}
+	for i := 1; i < len(names); i++ {
+		if names[i-1] > names[i] {
+			t.Fatalf("characters out of order: %q before %q", names[i-1], names[i])
+		}
+	}
+}
+
+func TestMovieCharactersHandler(t *testing.T) {
+	app := newTestApplication(t)
+	routes := app.routes()
+
+	tests := []struct {
+		target string
+		status int
+		count  int
+	}{
+		{target: "/v1/movies/4/characters", status: http.StatusOK, count: 17},
+		{target: "/v1/movies/a-new-hope/characters?gender=female", status: http.StatusOK, count: 2},
+		{target: "/v1/movies/42/characters", status: http.StatusNotFound},
+		{target: "/v1/movies/4/characters?sort=age", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			status, body := get(t, routes, tt.target)
+			if status != tt.status {
+				t.Fatalf("status %d, want %d: %s", status, tt.status, body["error"])
+			}
+			if status != http.StatusOK {
+				return
+			}
+
+			_, metadata := decodeCharacters(t, body)
+			if metadata.Count != tt.count {
+				t.Errorf("count %d, want %d", metadata.Count, tt.count)
+			}
+			if _, ok := body["links"]; !ok {
+				t.Error("response has no film links")
+			}
+		})
+	}
+}
+
+// decodeCharacters returns the names of the characters in a character
+// listing, and its metadata.
+func decodeCharacters(t *testing.T, body map[string]json.RawMessage) ([]string, Metadata) {
+	t.Helper()
+
+	var results []Result
+	err := json.Unmarshal(body["character"], &results)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var metadata Metadata
+	err = json.Unmarshal(body["metadata"], &metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, result := range results {
+		names = append(names, result.Name)
+	}
+
+	return names, metadata
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parseTrustedProxies parses a comma-separated list of proxy addresses and
+// CIDR ranges, such as "10.0.0.0/8,192.168.1.10".
+func parseTrustedProxies(spec string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q, expected an IP address or CIDR range", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q, expected an IP address or CIDR range", entry)
+		}
+		proxies = append(proxies, network)
+	}
+
+	return proxies, nil
+}
+
+// clientIP is the address a request came from, used to budget requests and
+// to tell clients apart: the connection's remote address, or, when that is
+// one of app's trusted proxies, the right-most X-Forwarded-For hop that
+// isn't. Hops left of it were written by the client and can't be trusted.
+func (app *application) clientIP(r *http.Request) string {
+	return clientIP(r, app.config.trustedProxies)
+}
+
+func clientIP(r *http.Request, trusted []*net.IPNet) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+
+	if !isTrustedProxy(net.ParseIP(ip), trusted) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			// a hop we can't read ends the chain we can vouch for
+			break
+		}
+
+		ip = hop.String()
+		if !isTrustedProxy(hop, trusted) {
+			break
+		}
+	}
+
+	return ip
+}
+
+func isTrustedProxy(ip net.IP, trusted []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
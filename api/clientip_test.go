@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		spec     string
+		contains []string
+		excludes []string
+		wantErr  bool
+	}{
+		{spec: ""},
+		{spec: "10.0.0.0/8", contains: []string{"10.1.2.3"}, excludes: []string{"11.0.0.1"}},
+		{spec: "192.168.1.10, 10.0.0.0/8", contains: []string{"192.168.1.10", "10.0.0.1"}, excludes: []string{"192.168.1.11"}},
+		{spec: "::1", contains: []string{"::1"}, excludes: []string{"127.0.0.1"}},
+		{spec: "proxy", wantErr: true},
+		{spec: "10.0.0.0/33", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		proxies, err := parseTrustedProxies(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTrustedProxies(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+
+		for _, ip := range tt.contains {
+			if !isTrustedProxy(net.ParseIP(ip), proxies) {
+				t.Errorf("parseTrustedProxies(%q) doesn't trust %s", tt.spec, ip)
+			}
+		}
+		for _, ip := range tt.excludes {
+			if isTrustedProxy(net.ParseIP(ip), proxies) {
+				t.Errorf("parseTrustedProxies(%q) trusts %s", tt.spec, ip)
+			}
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := parseTrustedProxies("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		xForwardedFor []string
+		trusted       bool
+		want          string
+	}{
+		{name: "remote address", remoteAddr: "203.0.113.7:5123", want: "203.0.113.7"},
+		{name: "ipv6 remote address", remoteAddr: "[2001:db8::1]:5123", want: "2001:db8::1"},
+		{name: "forwarded-for ignored without trusted proxies", remoteAddr: "203.0.113.7:5123", xForwardedFor: []string{"198.51.100.1"}, want: "203.0.113.7"},
+		{name: "forwarded-for ignored from an untrusted peer", remoteAddr: "203.0.113.7:5123", xForwardedFor: []string{"198.51.100.1"}, trusted: true, want: "203.0.113.7"},
+		{name: "hop before a trusted proxy", remoteAddr: "10.0.0.2:5123", xForwardedFor: []string{"198.51.100.1"}, trusted: true, want: "198.51.100.1"},
+		{name: "spoofed hops are skipped", remoteAddr: "10.0.0.2:5123", xForwardedFor: []string{"1.2.3.4, 198.51.100.1, 10.0.0.3"}, trusted: true, want: "198.51.100.1"},
+		{name: "hops across headers", remoteAddr: "10.0.0.2:5123", xForwardedFor: []string{"1.2.3.4", "198.51.100.1"}, trusted: true, want: "198.51.100.1"},
+		{name: "unreadable hop ends the chain", remoteAddr: "10.0.0.2:5123", xForwardedFor: []string{"198.51.100.1, garbage"}, trusted: true, want: "10.0.0.2"},
+		{name: "only trusted hops", remoteAddr: "10.0.0.2:5123", xForwardedFor: []string{"10.0.0.4"}, trusted: true, want: "10.0.0.4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/v1/movies", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.xForwardedFor {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+
+			app := newTestApplication(t)
+			if tt.trusted {
+				app.config.trustedProxies = trusted
+			}
+
+			if got := app.clientIP(r); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
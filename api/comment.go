@@ -1,29 +1,27 @@
 package api
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	custom_validator "github.com/JacobNewton007/busha-test/internals/custom_validator"
 	"github.com/JacobNewton007/busha-test/internals/data"
-	"github.com/go-playground/validator/v10"
+	"github.com/JacobNewton007/busha-test/internals/swapi"
 )
 
-func getClientIpAddr(req *http.Request) string {
-	clientIp := req.Header.Get("X-FORWARDED-FOR")
-	if clientIp != "" {
-		return clientIp
-	}
-	return req.RemoteAddr
-}
-
 func (app *application) CreateCommentHandler(w http.ResponseWriter, r *http.Request) {
 
 	var input struct {
 		Comment     string `json:"comment" validate:"required,min=4,max=500"`
 		Movie       string `json:"movie_name" validate:"required"`
 		CommenterIp string `json:"commenter_ip" validate:"required"`
+		ParentID    *int64 `json:"parent_id"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -32,24 +30,48 @@ func (app *application) CreateCommentHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	input.Movie = app.readMovieNameParams(r)
-	input.CommenterIp = getClientIpAddr(r)
+	// store comments against the catalogue's title and episode id, whatever
+	// spelling of the film the path used
+	film, err := app.commentFilm(r)
+	if err != nil {
+		app.swapiErrorResponse(w, r, err)
+		return
+	}
+
+	input.Movie = film.Title
+	input.CommenterIp = app.clientIP(r)
 
 	comment := &data.Comment{
 		Comment:     input.Comment,
 		Movie:       input.Movie,
+		EpisodeID:   &film.EpisodeID,
 		CommenterIp: input.CommenterIp,
+		Status:      data.StatusVisible,
+		ParentID:    input.ParentID,
 	}
 
-	validate := validator.New()
-
-	trans := custom_validator.Validator()
+	errs := app.validator.Struct(input, r.Header.Get("Accept-Language"))
+	if errs != nil {
+		app.failedValidationResponse(w, r, errs)
+		return
+	}
 
-	err = validate.Struct(comment)
-	if err != nil {
-		errs := custom_validator.TranslateError(err, trans)
+	// a reply has to stay within the thread of the movie it is posted on
+	if input.ParentID != nil {
+		_, err = app.models.Comments.Get(commentMovie(film), *input.ParentID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.failedValidationResponse(w, r, map[string]string{"parent_id": app.message(r, custom_validator.MsgParentNotFound, "parent_id")})
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
 
-		app.logger.Error(errs)
+	if app.config.comments.requireApproval {
+		comment.Status = data.StatusPending
 	}
 
 	err = app.models.Comments.Insert(comment)
@@ -58,34 +80,410 @@ func (app *application) CreateCommentHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	links := newCommentLinks(film, comment)
+
 	headers := make(http.Header)
-	headers.Set("Location", fmt.Sprintf("/v1/comments/%s", comment.Movie))
+	headers.Set("Location", links.Self)
 	// set movies key to expire when new comment is added to a movie
-	app.client.Expire(Ctx, "movies", time.Second * 1)
+	app.cache.Expire(r.Context(), "movies", time.Second)
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"comment": comment, "message": "comment created", "status": "success"}, headers)
+	err = app.writeJSON(w, http.StatusCreated, envelope{"comment": comment, "links": links, "message": "comment created", "status": "success"}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
+// commentLinks are the canonical urls of a comment, its reactions and the
+// film it belongs to.
+type commentLinks struct {
+	Self      string `json:"self"`
+	Reactions string `json:"reactions"`
+	Movie     string `json:"movie"`
+	Parent    string `json:"parent,omitempty"`
+}
+
+func newCommentLinks(film swapi.Film, comment *data.Comment) commentLinks {
+	film_links := newFilmLinks(film)
+	links := commentLinks{
+		Self:      fmt.Sprintf("%s/%d", film_links.Comments, comment.ID),
+		Reactions: fmt.Sprintf("%s/%d/reactions", film_links.Comments, comment.ID),
+		Movie:     film_links.Self,
+	}
+	if comment.ParentID != nil {
+		links.Parent = fmt.Sprintf("%s/%d", film_links.Comments, *comment.ParentID)
+	}
+	return links
+}
+
+// commentMovie is the key film's comments are stored and looked up under.
+func commentMovie(film swapi.Film) data.CommentMovie {
+	return data.CommentMovie{EpisodeID: film.EpisodeID, Title: film.Title}
+}
+
+// commentFilm resolves the :movie_name segment of a comment route, which
+// may be a film's episode id, slug or title.
+func (app *application) commentFilm(r *http.Request) (swapi.Film, error) {
+	return app.findFilm(r.Context(), app.readMovieNameParams(r))
+}
+
+const maxCommentsLimit = 100
+
+// defaultReplyDepth and maxReplyDepth bound how many levels of replies
+// ?view=tree nests under each top-level comment.
+const (
+	defaultReplyDepth = 3
+	maxReplyDepth     = 10
+)
+
+// readCommentFilters parses the limit, before_id/after_id cursors, sort and
+// since parameters of a comment listing.
+func (app *application) readCommentFilters(qs url.Values) (data.CommentFilters, error) {
+	filters := data.CommentFilters{Limit: 20, Sort: "-created_at"}
+
+	if qs.Get("limit") != "" {
+		limit, err := strconv.Atoi(qs.Get("limit"))
+		if err != nil || limit < 1 || limit > maxCommentsLimit {
+			return filters, fmt.Errorf("limit must be an integer between 1 and %d", maxCommentsLimit)
+		}
+		filters.Limit = limit
+	}
+
+	for key, dst := range map[string]*int64{"before_id": &filters.BeforeID, "after_id": &filters.AfterID} {
+		if qs.Get(key) == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(qs.Get(key), 10, 64)
+		if err != nil || id < 1 {
+			return filters, fmt.Errorf("%s must be a positive integer", key)
+		}
+		*dst = id
+	}
+
+	if filters.BeforeID != 0 && filters.AfterID != 0 {
+		return filters, errors.New("before_id and after_id cannot be used together")
+	}
+
+	filters.Sort = app.readString(qs, "sort", filters.Sort)
+	valid := false
+	for _, safe := range data.CommentSortSafelist {
+		valid = valid || filters.Sort == safe
+	}
+	if !valid {
+		return filters, fmt.Errorf("sort must be one of %s", strings.Join(data.CommentSortSafelist, ", "))
+	}
+
+	if since := qs.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return filters, errors.New("since must be an RFC 3339 timestamp")
+		}
+		filters.Since = t
+	}
+
+	return filters, nil
+}
+
 func (app *application) MovieCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	filters, err := app.readCommentFilters(qs)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	film, err := app.commentFilm(r)
+	if err != nil {
+		app.swapiErrorResponse(w, r, err)
+		return
+	}
+
+	var comments interface{}
+	var metadata data.CommentMetadata
+
+	switch app.readString(qs, "view", "flat") {
+	case "flat":
+		comments, metadata, err = app.models.Comments.GetCommentForMovie(commentMovie(film), filters)
+	case "tree":
+		maxDepth := defaultReplyDepth
+		if qs.Get("max_depth") != "" {
+			maxDepth, err = strconv.Atoi(qs.Get("max_depth"))
+			if err != nil || maxDepth < 0 || maxDepth > maxReplyDepth {
+				app.badRequestResponse(w, r, fmt.Errorf("max_depth must be an integer between 0 and %d", maxReplyDepth))
+				return
+			}
+		}
+		comments, metadata, err = app.models.Comments.GetThreads(commentMovie(film), filters, maxDepth)
+	default:
+		app.badRequestResponse(w, r, errors.New("view must be flat or tree"))
+		return
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidCursor):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"comments": comments, "totalRecords": metadata.Total, "metadata": metadata, "links": newFilmLinks(film), "message": "fetch comment successfully", "status": "success"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) ShowCommentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	film, err := app.commentFilm(r)
+	if err != nil {
+		app.swapiErrorResponse(w, r, err)
+		return
+	}
+
+	comment, err := app.models.Comments.Get(commentMovie(film), id)
+	if err != nil {
+		app.recordErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"comment": comment, "links": newCommentLinks(film, comment), "message": "fetch comment successfully", "status": "success"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) UpdateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	film, err := app.commentFilm(r)
+	if err != nil {
+		app.swapiErrorResponse(w, r, err)
+		return
+	}
+
+	comment, err := app.models.Comments.Get(commentMovie(film), id)
+	if err != nil {
+		app.recordErrorResponse(w, r, err)
+		return
+	}
+
+	expected, ok := app.authorizeCommentChange(w, r, comment)
+	if !ok {
+		return
+	}
+	if expected != comment.Version {
+		app.editConflictResponse(w, r)
+		return
+	}
 
 	var input struct {
-		Movie string `json:"movie_name"`
+		Comment *string `json:"comment" validate:"omitempty,min=4,max=500"`
 	}
 
-	input.Movie = app.readMovieNameParams(r)
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
 
-	comments, totalRecords, err := app.models.Comments.GetCommentForMovie(input.Movie)
+	errs := app.validator.Struct(input, r.Header.Get("Accept-Language"))
+	if errs != nil {
+		app.failedValidationResponse(w, r, errs)
+		return
+	}
+
+	if input.Comment != nil {
+		comment.Comment = *input.Comment
+	}
+
+	err = app.models.Comments.Update(comment)
+	if err != nil {
+		app.recordErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"comment": comment, "links": newCommentLinks(film, comment), "message": "comment updated", "status": "success"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) DeleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	film, err := app.commentFilm(r)
+	if err != nil {
+		app.swapiErrorResponse(w, r, err)
+		return
+	}
+
+	comment, err := app.models.Comments.Get(commentMovie(film), id)
+	if err != nil {
+		app.recordErrorResponse(w, r, err)
+		return
+	}
+
+	expected, ok := app.authorizeCommentChange(w, r, comment)
+	if !ok {
+		return
+	}
+
+	err = app.models.Comments.Delete(comment.ID, expected)
+	if err != nil {
+		app.recordErrorResponse(w, r, err)
+		return
+	}
+
+	// the movie list caches comment counts
+	app.cache.Expire(r.Context(), "movies", time.Second)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"links": newFilmLinks(film), "message": "comment successfully deleted", "status": "success"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// authorizeCommentChange checks that a request may edit or delete comment:
+// it must come from the client IP that posted the comment and carry the
+// version it expects to change in X-Expected-Version. It returns that
+// version, or responds with the reason and ok=false.
+func (app *application) authorizeCommentChange(w http.ResponseWriter, r *http.Request, comment *data.Comment) (int32, bool) {
+	if !sameClient(comment.CommenterIp, app.clientIP(r)) {
+		app.notPermittedResponse(w, r)
+		return 0, false
+	}
+
+	expected, ok, err := app.readExpectedVersion(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return 0, false
+	}
+	if !ok {
+		app.versionRequiredResponse(w, r)
+		return 0, false
+	}
+
+	return expected, true
+}
+
+// sameClient reports whether a comment's stored commenter IP is ip. Older
+// comments stored the remote address with its port, which is ignored.
+func sameClient(commenterIP string, ip string) bool {
+	if host, _, err := net.SplitHostPort(commenterIP); err == nil {
+		commenterIP = host
+	}
+	return commenterIP == ip
+}
+
+func (app *application) CreateReactionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Reaction string `json:"reaction" validate:"required"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"comments": comments, "totalRecords": totalRecords, "message": "fetch comment successfully", "status": "success"}, nil)
+	errs := app.validator.Struct(input, r.Header.Get("Accept-Language"))
+	if errs == nil {
+		errs = custom_validator.Errors{}
+	}
+
+	valid := false
+	for _, reaction := range data.ReactionSafelist {
+		valid = valid || input.Reaction == reaction
+	}
+	errs.Check(valid, "reaction", app.message(r, custom_validator.MsgOneOf, "reaction", strings.Join(data.ReactionSafelist, ", ")))
+
+	if len(errs) > 0 {
+		app.failedValidationResponse(w, r, errs)
+		return
+	}
+
+	film, err := app.commentFilm(r)
+	if err != nil {
+		app.swapiErrorResponse(w, r, err)
+		return
+	}
+
+	comment, err := app.models.Comments.Get(commentMovie(film), id)
+	if err != nil {
+		app.recordErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Reactions.Set(comment.ID, app.clientIP(r), input.Reaction)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
+
+	app.writeCommentReactions(w, r, film, comment.ID, "reaction saved")
+}
+
+func (app *application) DeleteReactionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	film, err := app.commentFilm(r)
+	if err != nil {
+		app.swapiErrorResponse(w, r, err)
+		return
+	}
+
+	comment, err := app.models.Comments.Get(commentMovie(film), id)
+	if err != nil {
+		app.recordErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Reactions.Delete(comment.ID, app.clientIP(r))
+	if err != nil {
+		app.recordErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeCommentReactions(w, r, film, comment.ID, "reaction removed")
+}
+
+// writeCommentReactions responds with the comment's reaction counts as
+// they stand after a change.
+func (app *application) writeCommentReactions(w http.ResponseWriter, r *http.Request, film swapi.Film, id int64, message string) {
+	comment, err := app.models.Comments.Get(commentMovie(film), id)
+	if err != nil {
+		app.recordErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"reactions": comment.Reactions, "score": comment.Score, "links": newCommentLinks(film, comment), "message": message, "status": "success"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
 }
@@ -0,0 +1,521 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	custom_validator "github.com/JacobNewton007/busha-test/internals/custom_validator"
+	"github.com/JacobNewton007/busha-test/internals/data"
+	"github.com/lib/pq"
+)
+
+func TestReadCommentFilters(t *testing.T) {
+	since := time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		query   string
+		want    data.CommentFilters
+		wantErr bool
+	}{
+		{query: "", want: data.CommentFilters{Limit: 20, Sort: "-created_at"}},
+		{query: "limit=5&after_id=9&sort=created_at", want: data.CommentFilters{Limit: 5, AfterID: 9, Sort: "created_at"}},
+		{query: "before_id=3&since=2023-01-02T15:04:05Z", want: data.CommentFilters{Limit: 20, BeforeID: 3, Sort: "-created_at", Since: since}},
+		{query: "limit=0", wantErr: true},
+		{query: "limit=101", wantErr: true},
+		{query: "limit=ten", wantErr: true},
+		{query: "before_id=0", wantErr: true},
+		{query: "after_id=-4", wantErr: true},
+		{query: "before_id=3&after_id=9", wantErr: true},
+		{query: "sort=top", want: data.CommentFilters{Limit: 20, Sort: "top"}},
+		{query: "sort=comment", wantErr: true},
+		{query: "since=yesterday", wantErr: true},
+	}
+
+	app := newTestApplication(t)
+
+	for _, tt := range tests {
+		got, err := app.readCommentFilters(mustParseQuery(t, tt.query))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("readCommentFilters(%q) = %+v, want an error", tt.query, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("readCommentFilters(%q): %v", tt.query, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("readCommentFilters(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestMovieCommentsHandler(t *testing.T) {
+	t.Run("page after a cursor", func(t *testing.T) {
+		app := newTestApplication(t)
+		mock := mockModels(t, app)
+
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS")).
+			WithArgs(int64(7), 4, "A New Hope", time.Time{}, false).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*)")).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectQuery(regexp.QuoteMeta("ORDER BY rank DESC, created_at DESC, id DESC")).
+			WithArgs("A New Hope", time.Time{}, int64(7), int64(0), 3, false, 4).
+			WillReturnRows(commentRows(
+				&data.Comment{ID: 6, Comment: "Great film", Movie: "A New Hope", CommenterIp: "203.0.113.7", Version: 1, Status: data.StatusVisible},
+				&data.Comment{ID: 5, Comment: "Loved it", Movie: "A New Hope", CommenterIp: "203.0.113.8", Version: 1, Status: data.StatusVisible},
+			))
+		mock.ExpectQuery(regexp.QuoteMeta("GROUP BY comment_id, reaction")).
+			WillReturnRows(reactionRows())
+
+		status, body := get(t, app.routes(), "/v1/comments/A%20New%20Hope?before_id=7&limit=2")
+		if status != http.StatusOK {
+			t.Fatalf("status %d, want %d", status, http.StatusOK)
+		}
+
+		var metadata data.CommentMetadata
+		if err := json.Unmarshal(body["metadata"], &metadata); err != nil {
+			t.Fatal(err)
+		}
+		if metadata.Total != 3 || metadata.NextCursor != nil {
+			t.Errorf("metadata = %+v, want a total of 3 and no next cursor", metadata)
+		}
+		if metadata.PrevCursor == nil || *metadata.PrevCursor != (data.CommentCursor{Param: "after_id", ID: 6}) {
+			t.Errorf("prev cursor = %+v, want after_id=6", metadata.PrevCursor)
+		}
+	})
+
+	t.Run("unknown cursor", func(t *testing.T) {
+		app := newTestApplication(t)
+		mock := mockModels(t, app)
+
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS")).
+			WithArgs(int64(99), 4, "A New Hope", time.Time{}, false).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		status, _ := get(t, app.routes(), "/v1/comments/A%20New%20Hope?after_id=99")
+		if status != http.StatusBadRequest {
+			t.Errorf("status %d, want %d", status, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("tree", func(t *testing.T) {
+		app := newTestApplication(t)
+		mock := mockModels(t, app)
+
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*)")).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery(regexp.QuoteMeta("ORDER BY rank DESC, created_at DESC, id DESC")).
+			WithArgs("A New Hope", time.Time{}, int64(0), int64(0), 21, true, 4).
+			WillReturnRows(commentRows(
+				&data.Comment{ID: 1, Comment: "Great film", Movie: "A New Hope", CommenterIp: "203.0.113.7", Version: 1, Status: data.StatusVisible},
+			))
+		mock.ExpectQuery(regexp.QuoteMeta("GROUP BY comment_id, reaction")).
+			WillReturnRows(reactionRows())
+		mock.ExpectQuery(regexp.QuoteMeta("WITH RECURSIVE thread")).
+			WithArgs(pq.Array([]int64{1}), 1).
+			WillReturnRows(commentRows())
+		mock.ExpectQuery(regexp.QuoteMeta("GROUP BY parent_id")).
+			WillReturnRows(sqlmock.NewRows([]string{"parent_id", "count"}))
+
+		status, body := get(t, app.routes(), "/v1/comments/4?view=tree&max_depth=1")
+		if status != http.StatusOK {
+			t.Fatalf("status %d, want %d", status, http.StatusOK)
+		}
+
+		var threads []data.CommentNode
+		if err := json.Unmarshal(body["comments"], &threads); err != nil {
+			t.Fatal(err)
+		}
+		if len(threads) != 1 || threads[0].ID != 1 || threads[0].Replies == nil {
+			t.Errorf("comments = %+v, want comment 1 with an empty list of replies", threads)
+		}
+	})
+
+	for _, query := range []string{"limit=0", "view=nested", "view=tree&max_depth=11", "view=tree&max_depth=-1"} {
+		t.Run(query, func(t *testing.T) {
+			app := newTestApplication(t)
+			mockModels(t, app)
+
+			status, _ := get(t, app.routes(), "/v1/comments/A%20New%20Hope?"+query)
+			if status != http.StatusBadRequest {
+				t.Errorf("status %d, want %d", status, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestCreateCommentHandlerUnknownParent(t *testing.T) {
+	app := newTestApplication(t)
+	mock := mockModels(t, app)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM comments")).
+		WithArgs(int64(9), 4, "A New Hope").
+		WillReturnRows(commentRows())
+
+	r := httptest.NewRequest(http.MethodPost, "/V1/comments/A%20New%20Hope", strings.NewReader(`{"comment": "Agreed, great film", "parent_id": 9}`))
+	rr := httptest.NewRecorder()
+
+	app.routes().ServeHTTP(rr, r)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status %d, want %d: %s", rr.Code, http.StatusUnprocessableEntity, rr.Body)
+	}
+}
+
+func TestCreateCommentHandler(t *testing.T) {
+	// the slug, title and episode id all store the catalogue's title and episode id
+	for _, movie := range []string{"a-new-hope", "a%20new%20HOPE", "4"} {
+		t.Run(movie, func(t *testing.T) {
+			app := newTestApplication(t)
+			mock := mockModels(t, app)
+
+			mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO comments")).
+				WithArgs("Great film", "A New Hope", 4, "203.0.113.7", data.StatusVisible, nil).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "version"}).AddRow(1, time.Now(), 1))
+
+			r := httptest.NewRequest(http.MethodPost, "/V1/comments/"+movie, strings.NewReader(`{"comment": "Great film"}`))
+			r.RemoteAddr = "203.0.113.7:5123"
+			rr := httptest.NewRecorder()
+
+			app.routes().ServeHTTP(rr, r)
+			if rr.Code != http.StatusCreated {
+				t.Fatalf("status %d, want %d: %s", rr.Code, http.StatusCreated, rr.Body)
+			}
+
+			var body struct {
+				Comment data.Comment `json:"comment"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Comment.Movie != "A New Hope" || body.Comment.EpisodeID == nil || *body.Comment.EpisodeID != 4 {
+				t.Errorf("comment = %+v, want one on A New Hope, episode 4", body.Comment)
+			}
+			if got, want := rr.Header().Get("Location"), "/v1/comments/a-new-hope/1"; got != want {
+				t.Errorf("Location %q, want %q", got, want)
+			}
+		})
+	}
+
+	t.Run("unknown film", func(t *testing.T) {
+		app := newTestApplication(t)
+		mockModels(t, app)
+
+		r := httptest.NewRequest(http.MethodPost, "/V1/comments/a-new-hop", strings.NewReader(`{"comment": "Great film"}`))
+		rr := httptest.NewRecorder()
+
+		app.routes().ServeHTTP(rr, r)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("status %d, want %d", rr.Code, http.StatusNotFound)
+		}
+	})
+}
+
+func TestCreateCommentHandlerValidation(t *testing.T) {
+	tests := []struct {
+		body  string
+		field string
+	}{
+		{body: `{}`, field: "comment"},
+		{body: `{"comment": "ok"}`, field: "comment"},
+		{body: `{"comment": "` + strings.Repeat("a", 501) + `"}`, field: "comment"},
+	}
+
+	for _, tt := range tests {
+		app := newTestApplication(t)
+		mockModels(t, app)
+
+		r := httptest.NewRequest(http.MethodPost, "/V1/comments/A%20New%20Hope", strings.NewReader(tt.body))
+		rr := httptest.NewRecorder()
+
+		app.routes().ServeHTTP(rr, r)
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("POST %.20s: status %d, want %d", tt.body, rr.Code, http.StatusUnprocessableEntity)
+			continue
+		}
+
+		var body struct {
+			Error map[string]string `json:"error"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Error[tt.field] == "" {
+			t.Errorf("POST %.20s: errors %v, want one for %s", tt.body, body.Error, tt.field)
+		}
+	}
+}
+
+func TestSameClient(t *testing.T) {
+	tests := []struct {
+		commenterIP string
+		ip          string
+		want        bool
+	}{
+		{commenterIP: "203.0.113.7", ip: "203.0.113.7", want: true},
+		{commenterIP: "203.0.113.7:5123", ip: "203.0.113.7", want: true},
+		{commenterIP: "[2001:db8::1]:5123", ip: "2001:db8::1", want: true},
+		{commenterIP: "203.0.113.7", ip: "203.0.113.8"},
+		{commenterIP: "", ip: "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		if got := sameClient(tt.commenterIP, tt.ip); got != tt.want {
+			t.Errorf("sameClient(%q, %q) = %v, want %v", tt.commenterIP, tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestAuthorizeCommentChange(t *testing.T) {
+	comment := &data.Comment{ID: 1, CommenterIp: "203.0.113.7", Version: 3}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		version    string
+		status     int
+		ok         bool
+		want       int32
+	}{
+		{name: "commenter with a version", remoteAddr: "203.0.113.7:5123", version: "3", ok: true, want: 3},
+		{name: "stale version is left to the update", remoteAddr: "203.0.113.7:5123", version: "2", ok: true, want: 2},
+		{name: "another client", remoteAddr: "203.0.113.8:5123", version: "3", status: http.StatusForbidden},
+		{name: "missing version", remoteAddr: "203.0.113.7:5123", status: http.StatusPreconditionRequired},
+		{name: "malformed version", remoteAddr: "203.0.113.7:5123", version: "three", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			r := httptest.NewRequest(http.MethodPatch, "/v1/comments/4/1", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.version != "" {
+				r.Header.Set("X-Expected-Version", tt.version)
+			}
+			rr := httptest.NewRecorder()
+
+			got, ok := app.authorizeCommentChange(rr, r, comment)
+			if ok != tt.ok || got != tt.want {
+				t.Fatalf("authorizeCommentChange = %d, %v, want %d, %v", got, ok, tt.want, tt.ok)
+			}
+			if !ok && rr.Code != tt.status {
+				t.Errorf("status %d, want %d", rr.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestUpdateCommentHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		version    string
+		expect     func(mock sqlmock.Sqlmock)
+		status     int
+	}{
+		{
+			name:       "commenter with the current version",
+			remoteAddr: "203.0.113.7:5123",
+			version:    "3",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("UPDATE comments")).
+					WithArgs("Even better the second time", int64(1), int32(3)).
+					WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(4))
+			},
+			status: http.StatusOK,
+		},
+		{name: "stale version", remoteAddr: "203.0.113.7:5123", version: "2", status: http.StatusConflict},
+		{
+			name:       "changed by someone else first",
+			remoteAddr: "203.0.113.7:5123",
+			version:    "3",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("UPDATE comments")).
+					WillReturnRows(sqlmock.NewRows([]string{"version"}))
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS")).
+					WithArgs(int64(1), pq.Array([]string{data.StatusDeleted})).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			},
+			status: http.StatusConflict,
+		},
+		{name: "another client", remoteAddr: "203.0.113.8:5123", version: "3", status: http.StatusForbidden},
+		{name: "missing version", remoteAddr: "203.0.113.7:5123", status: http.StatusPreconditionRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			mock := mockModels(t, app)
+
+			mock.ExpectQuery(regexp.QuoteMeta("FROM comments")).
+				WithArgs(int64(1), 4, "A New Hope").
+				WillReturnRows(commentRows(
+					&data.Comment{ID: 1, Comment: "Great film", Movie: "A New Hope", CommenterIp: "203.0.113.7", Version: 3, Status: data.StatusVisible},
+				))
+			mock.ExpectQuery(regexp.QuoteMeta("GROUP BY comment_id, reaction")).
+				WillReturnRows(reactionRows())
+			if tt.expect != nil {
+				tt.expect(mock)
+			}
+
+			r := httptest.NewRequest(http.MethodPatch, "/v1/comments/A%20New%20Hope/1", strings.NewReader(`{"comment": "Even better the second time"}`))
+			r.RemoteAddr = tt.remoteAddr
+			if tt.version != "" {
+				r.Header.Set("X-Expected-Version", tt.version)
+			}
+			rr := httptest.NewRecorder()
+
+			app.routes().ServeHTTP(rr, r)
+			if rr.Code != tt.status {
+				t.Errorf("status %d, want %d: %s", rr.Code, tt.status, rr.Body)
+			}
+		})
+	}
+}
+
+func TestShowCommentHandlerNotFound(t *testing.T) {
+	app := newTestApplication(t)
+	mock := mockModels(t, app)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM comments")).
+		WithArgs(int64(9), 4, "A New Hope").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	status, _ := get(t, app.routes(), "/v1/comments/A%20New%20Hope/9")
+	if status != http.StatusNotFound {
+		t.Errorf("status %d, want %d", status, http.StatusNotFound)
+	}
+}
+
+func TestCreateReactionHandler(t *testing.T) {
+	comment := &data.Comment{ID: 1, Comment: "Great film", Movie: "A New Hope", CommenterIp: "203.0.113.8", Version: 1, Status: data.StatusVisible}
+
+	t.Run("vote", func(t *testing.T) {
+		app := newTestApplication(t)
+		mock := mockModels(t, app)
+
+		mock.ExpectQuery(regexp.QuoteMeta("FROM comments")).
+			WithArgs(int64(1), 4, "A New Hope").
+			WillReturnRows(commentRows(comment))
+		mock.ExpectQuery(regexp.QuoteMeta("GROUP BY comment_id, reaction")).
+			WillReturnRows(reactionRows())
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO comment_reactions")).
+			WithArgs(int64(1), "203.0.113.7", "up").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta("FROM comments")).
+			WithArgs(int64(1), 4, "A New Hope").
+			WillReturnRows(commentRows(comment))
+		mock.ExpectQuery(regexp.QuoteMeta("GROUP BY comment_id, reaction")).
+			WillReturnRows(reactionRows().AddRow(1, "up", 1))
+
+		r := httptest.NewRequest(http.MethodPost, "/v1/comments/A%20New%20Hope/1/reactions", strings.NewReader(`{"reaction": "up"}`))
+		r.RemoteAddr = "203.0.113.7:5123"
+		rr := httptest.NewRecorder()
+
+		app.routes().ServeHTTP(rr, r)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("status %d, want %d: %s", rr.Code, http.StatusOK, rr.Body)
+		}
+
+		var body struct {
+			Reactions map[string]int `json:"reactions"`
+			Score     int            `json:"score"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Score != 1 || body.Reactions["up"] != 1 {
+			t.Errorf("score %d and reactions %v, want a single up vote", body.Score, body.Reactions)
+		}
+	})
+
+	t.Run("unknown reaction", func(t *testing.T) {
+		app := newTestApplication(t)
+		mockModels(t, app)
+
+		r := httptest.NewRequest(http.MethodPost, "/v1/comments/A%20New%20Hope/1/reactions", strings.NewReader(`{"reaction": "meh"}`))
+		rr := httptest.NewRecorder()
+
+		app.routes().ServeHTTP(rr, r)
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("status %d, want %d", rr.Code, http.StatusUnprocessableEntity)
+		}
+	})
+}
+
+func TestDeleteReactionHandlerWithoutReaction(t *testing.T) {
+	app := newTestApplication(t)
+	mock := mockModels(t, app)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM comments")).
+		WithArgs(int64(1), 4, "A New Hope").
+		WillReturnRows(commentRows(&data.Comment{ID: 1, Comment: "Great film", Movie: "A New Hope", Version: 1, Status: data.StatusVisible}))
+	mock.ExpectQuery(regexp.QuoteMeta("GROUP BY comment_id, reaction")).
+		WillReturnRows(reactionRows())
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM comment_reactions")).
+		WithArgs(int64(1), "203.0.113.7").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	r := httptest.NewRequest(http.MethodDelete, "/v1/comments/A%20New%20Hope/1/reactions", nil)
+	r.RemoteAddr = "203.0.113.7:5123"
+	rr := httptest.NewRecorder()
+
+	app.routes().ServeHTTP(rr, r)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("status %d, want %d", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestLocalizedErrorResponses(t *testing.T) {
+	app := newTestApplication(t)
+	mock := mockModels(t, app)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM comments")).
+		WithArgs(int64(1), 4, "A New Hope").
+		WillReturnRows(commentRows(&data.Comment{ID: 1, Comment: "Great film", Movie: "A New Hope", CommenterIp: "203.0.113.7", Version: 3, Status: data.StatusVisible}))
+	mock.ExpectQuery(regexp.QuoteMeta("GROUP BY comment_id, reaction")).
+		WillReturnRows(reactionRows())
+
+	tests := []struct {
+		method         string
+		target         string
+		acceptLanguage string
+		status         int
+		key            string
+	}{
+		{method: http.MethodGet, target: "/v1/nowhere", acceptLanguage: "es", status: http.StatusNotFound, key: custom_validator.MsgNotFound},
+		{method: http.MethodPatch, target: "/v1/comments/A%20New%20Hope/1", acceptLanguage: "fr-CA, en;q=0.5", status: http.StatusForbidden, key: custom_validator.MsgNotPermitted},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(`{"comment": "Even better the second time"}`))
+		r.RemoteAddr = "203.0.113.8:5123"
+		r.Header.Set("Accept-Language", tt.acceptLanguage)
+		rr := httptest.NewRecorder()
+
+		app.routes().ServeHTTP(rr, r)
+		if rr.Code != tt.status {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.target, rr.Code, tt.status)
+			continue
+		}
+
+		var body struct {
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if want := app.validator.Message(tt.acceptLanguage, tt.key); body.Error != want {
+			t.Errorf("%s %s: error %q, want %q", tt.method, tt.target, body.Error, want)
+		}
+	}
+}
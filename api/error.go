@@ -1,10 +1,19 @@
 package api
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
+
+	custom_validator "github.com/JacobNewton007/busha-test/internals/custom_validator"
+	"github.com/JacobNewton007/busha-test/internals/data"
+	"github.com/JacobNewton007/busha-test/internals/swapi"
 )
 
+// message localizes a catalog message for the request's Accept-Language.
+func (app *application) message(r *http.Request, key string, params ...string) string {
+	return app.validator.Message(r.Header.Get("Accept-Language"), key, params...)
+}
+
 func (app *application) logError(r *http.Request, err error) {
 	app.logger.Errorw(err.Error(), map[string]string{
 		"request_method": r.Method,
@@ -25,24 +34,73 @@ func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, st
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.logError(r, err)
 
-	message := "the server encoutered a problem and could not process your request"
+	message := app.message(r, custom_validator.MsgServerError)
 	app.errorResponse(w, r, http.StatusInternalServerError, message)
 }
 
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
-	message := "the requested resource could not be found"
+	message := app.message(r, custom_validator.MsgNotFound)
 	app.errorResponse(w, r, http.StatusNotFound, message)
 }
 
 func (app *application) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
-	message := fmt.Sprintf("the %s method is not supported fot this resource", r.Method)
+	message := app.message(r, custom_validator.MsgMethodNotAllowed, r.Method)
 	app.errorResponse(w, r, http.StatusMethodNotAllowed, message)
 }
 
-func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.message(r, custom_validator.MsgEditConflict)
+	app.errorResponse(w, r, http.StatusConflict, message)
 }
 
+func (app *application) versionRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.message(r, custom_validator.MsgVersionRequired)
+	app.errorResponse(w, r, http.StatusPreconditionRequired, message)
+}
 
+func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.message(r, custom_validator.MsgNotPermitted)
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
 
+// recordErrorResponse maps the errors returned by the data models to a
+// response: missing records are a 404, version mismatches a 409.
+func (app *application) recordErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+	case errors.Is(err, data.ErrEditConflict):
+		app.editConflictResponse(w, r)
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.message(r, custom_validator.MsgInvalidToken)
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
 
+func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.message(r, custom_validator.MsgRateLimited)
+	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+}
+
+func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
+}
+
+func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+}
+
+// swapiErrorResponse reports a failed SWAPI lookup: a missing resource is a
+// 404, anything else is a server error.
+func (app *application) swapiErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, swapi.ErrNotFound):
+		app.notFoundResponse(w, r)
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -1,31 +1,94 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"math"
 	"net/http"
 	"net/url"
 	"sort"
 	"strconv"
 	"strings"
+	"unicode"
 
+	"github.com/JacobNewton007/busha-test/internals/data"
 	"github.com/julienschmidt/httprouter"
 )
 
-
-func (app *application) readMovieNameParams(r *http.Request) string  {
+func (app *application) readMovieNameParams(r *http.Request) string {
 	params := httprouter.ParamsFromContext(r.Context())
 
 	movie_name := params.ByName("movie_name")
 
-
 	return movie_name
 }
 
+func (app *application) readIDParam(r *http.Request) (int64, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.ParseInt(params.ByName("id"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid id parameter")
+	}
+
+	return id, nil
+}
+
+// readExpectedVersion reads the X-Expected-Version header clients send to
+// make an update or delete conditional on the version they saw. ok is
+// false when the header is missing.
+func (app *application) readExpectedVersion(r *http.Request) (int32, bool, error) {
+	header := r.Header.Get("X-Expected-Version")
+	if header == "" {
+		return 0, false, nil
+	}
+
+	version, err := strconv.ParseInt(header, 10, 32)
+	if err != nil {
+		return 0, false, errors.New("X-Expected-Version must be an integer")
+	}
+
+	return int32(version), true, nil
+}
+
+func (app *application) readMovieIDParams(r *http.Request) string {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	return params.ByName("id")
+}
+
+// slugify lowercases s and joins its runs of letters and digits with
+// dashes, so "The Empire Strikes Back" becomes "the-empire-strikes-back".
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+
+	for _, c := range strings.ToLower(s) {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(c)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+
+	return b.String()
+}
+
+// cacheAside reads key from the cache into dst, caching what fetch returns
+// on a miss. Concurrent misses share one fetch, and a stale value is served
+// while a single background fetch refreshes it; see cache.Loader. fetch runs
+// detached from the request, so it must not write to dst.
+func (app *application) cacheAside(ctx context.Context, key string, dst interface{}, fetch func(ctx context.Context) (interface{}, error)) error {
+	return app.loader.Load(ctx, key, dst, fetch)
+}
+
 // Define an envelope type
 type envelope map[string]interface{}
 
@@ -102,125 +165,394 @@ func (app *application) readString(qs url.Values, key string, defaultValue strin
 	return s
 }
 
-func (app *application) fetchUrl(url string) ([]byte, error) {
-	client := http.Client{}
+const cmPerInch = 2.54
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
+// FeetInches is a height broken down into whole feet and the remaining
+// inches, e.g. 5ft 8.5in.
+type FeetInches struct {
+	Feet   int     `json:"feet"`
+	Inches float64 `json:"inches"`
+}
+
+func toFeetInches(cm float64) FeetInches {
+	inches := math.Round(cm/cmPerInch*10) / 10
+	feet := math.Floor(inches / 12)
+	return FeetInches{
+		Feet:   int(feet),
+		Inches: math.Round((inches-feet*12)*10) / 10,
 	}
-	req.Header.Set("Content-Type", "application/json")
-	res, err := client.Do(req)
-	if err != nil {
-		return nil, err
+}
+
+func (f FeetInches) String() string {
+	return fmt.Sprintf("%dft %sin", f.Feet, strconv.FormatFloat(f.Inches, 'f', -1, 64))
+}
+
+// HeightStats summarises the characters whose height SWAPI knows; "unknown"
+// heights are counted out rather than treated as 0cm.
+type HeightStats struct {
+	Units         string     `json:"units"`
+	Total         string     `json:"total"`
+	Mean          string     `json:"mean"`
+	Known         int        `json:"known"`
+	TotalCm       float64    `json:"total_cm"`
+	MinCm         float64    `json:"min_cm"`
+	MaxCm         float64    `json:"max_cm"`
+	MeanCm        float64    `json:"mean_cm"`
+	MedianCm      float64    `json:"median_cm"`
+	TotalImperial FeetInches `json:"total_imperial"`
+	MeanImperial  FeetInches `json:"mean_imperial"`
+}
+
+func (app *application) readUnits(qs url.Values) (string, error) {
+	units := strings.ToLower(app.readString(qs, "units", "metric"))
+	if units != "metric" && units != "imperial" {
+		return "", errors.New("units must be metric or imperial")
 	}
+	return units, nil
+}
 
-	defer res.Body.Close()
-	// var dsc  interface{}
-	body, err := ioutil.ReadAll(res.Body)
+func (app *application) createCharacterMetaData(character Character, units string) Metadata {
+	heights := app.findHeights(character)
+	summary := data.PeopleSummary{Total: len(character.Results), KnownHeight: len(heights)}
 
-	if err != nil {
-		return nil, err
-	}
-
-	return body, nil
-}
-
-func (app *application) createCharacterMetaData(metadata Metadata, totalHeight int, character Character) Metadata {
-	height_to_inches := (float64(totalHeight)/ 2.5) 
-	height_to_feet := (float64(totalHeight)/ 30.48)
-	metadata = Metadata{
-		Feets: (math.Round(height_to_feet*100)/100),
-		Inches: (math.Round(height_to_inches*100)/100),
-		Count: len(character.Results),
-	}
-
-	return metadata
-}
-
-// sort either asc || desc 
-func (app *application) sortBy(sort_value string, charactar Character) Character {
-	if ( strings.HasPrefix(sort_value, "-")) {
-		value := strings.TrimPrefix(sort_value, "-")
-		switch value {
-		case "name":
-			sort.SliceStable(character.Results, func(i, j int) bool {
-				return character.Results[i].Name > character.Results[j].Name
-			})
-		
-		case "gender":
-			sort.SliceStable(character.Results, func(i, j int) bool {
-				return character.Results[i].Gender > character.Results[j].Gender
-			})
-	
-		case "height":
-			sort.SliceStable(character.Results, func(i, j int) bool {
-				x, _ := strconv.ParseInt(charactar.Results[i].Height, 10, 64)
-				y, _ := strconv.ParseInt(charactar.Results[j].Height, 10, 64)
-				return int(x) >  int(y)
-			})
+	if len(heights) > 0 {
+		sort.Float64s(heights)
+		for _, height := range heights {
+			summary.TotalCm += height
 		}
+
+		summary.MinCm = heights[0]
+		summary.MaxCm = heights[len(heights)-1]
+
+		middle := len(heights) / 2
+		if len(heights)%2 == 0 {
+			summary.MedianCm = (heights[middle-1] + heights[middle]) / 2
+		} else {
+			summary.MedianCm = heights[middle]
+		}
+	}
+
+	return newCharacterMetadata(summary, units)
+}
+
+// newCharacterMetadata formats the count and height summary of a character
+// set, however it was computed, in the requested units.
+func newCharacterMetadata(summary data.PeopleSummary, units string) Metadata {
+	stats := HeightStats{
+		Units:    units,
+		Known:    summary.KnownHeight,
+		TotalCm:  summary.TotalCm,
+		MinCm:    summary.MinCm,
+		MaxCm:    summary.MaxCm,
+		MedianCm: summary.MedianCm,
+	}
+	if stats.Known > 0 {
+		stats.MeanCm = math.Round(stats.TotalCm/float64(stats.Known)*10) / 10
+	}
+
+	stats.TotalImperial = toFeetInches(stats.TotalCm)
+	stats.MeanImperial = toFeetInches(stats.MeanCm)
+
+	if units == "imperial" {
+		stats.Total = stats.TotalImperial.String()
+		stats.Mean = stats.MeanImperial.String()
 	} else {
-		switch sort_value {
-		case "name":
-			sort.SliceStable(character.Results, func(i, j int) bool {
-				return character.Results[i].Name < character.Results[j].Name
-			})
-		
-		case "gender":
-			sort.SliceStable(character.Results, func(i, j int) bool {
-				return character.Results[i].Gender < character.Results[j].Gender
-			})
-	
-		case "height":
-			sort.SliceStable(character.Results, func(i, j int) bool {
-				x, _ := strconv.ParseInt(charactar.Results[i].Height, 10, 64)
-				y, _ := strconv.ParseInt(charactar.Results[j].Height, 10, 64)
-				return int(x) <  int(y)
-			})
+		stats.Total = fmt.Sprintf("%scm", strconv.FormatFloat(stats.TotalCm, 'f', -1, 64))
+		stats.Mean = fmt.Sprintf("%scm", strconv.FormatFloat(stats.MeanCm, 'f', -1, 64))
+	}
+
+	return Metadata{
+		Feets:  stats.TotalImperial.Feet,
+		Inches: stats.TotalImperial.Inches,
+		Count:  summary.Total,
+		Height: stats,
+	}
+}
+
+// sortKey is one field of a sort expression such as "gender,-height,name".
+type sortKey struct {
+	field string
+	desc  bool
+}
+
+// characterSortFields compares two characters on a single field. The bool
+// results report whether each side has a value; characters without one
+// sort after those that do in either direction.
+var characterSortFields = map[string]func(a, b Result) (int, bool, bool){
+	"name": func(a, b Result) (int, bool, bool) {
+		return strings.Compare(a.Name, b.Name), true, true
+	},
+	"gender": func(a, b Result) (int, bool, bool) {
+		return strings.Compare(a.Gender, b.Gender), true, true
+	},
+	"height": func(a, b Result) (int, bool, bool) {
+		return compareNumbers(a.Height, b.Height)
+	},
+	"mass": func(a, b Result) (int, bool, bool) {
+		return compareNumbers(a.Mass, b.Mass)
+	},
+	"birth_year": func(a, b Result) (int, bool, bool) {
+		x, okX := parseBirthYear(a.BirthYear)
+		y, okY := parseBirthYear(b.BirthYear)
+		return compareFloats(x, y), okX, okY
+	},
+	"homeworld": func(a, b Result) (int, bool, bool) {
+		return strings.Compare(a.Homeworld, b.Homeworld), a.Homeworld != "" && a.Homeworld != "unknown", b.Homeworld != "" && b.Homeworld != "unknown"
+	},
+}
+
+var characterSortFieldNames = []string{"name", "gender", "height", "mass", "birth_year", "homeworld"}
+
+// parseSort parses a comma-separated sort expression, where a leading "-"
+// sorts that field in descending order.
+func (app *application) parseSort(sort_value string) ([]sortKey, error) {
+	var keys []sortKey
+
+	for _, field := range strings.Split(sort_value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		key := sortKey{field: strings.TrimPrefix(field, "-"), desc: strings.HasPrefix(field, "-")}
+		if _, ok := characterSortFields[key.field]; !ok {
+			return nil, fmt.Errorf("invalid sort field %q, sort must be a comma-separated list of %s (prefix a field with - to sort descending)", key.field, strings.Join(characterSortFieldNames, ", "))
 		}
+		keys = append(keys, key)
 	}
+
+	return keys, nil
+}
+
+// sortBy orders characters by each key in turn. The sort is stable, so
+// characters equal on every key keep their SWAPI order.
+func (app *application) sortBy(keys []sortKey, charactar Character) Character {
+	sort.SliceStable(charactar.Results, func(i, j int) bool {
+		for _, key := range keys {
+			cmp, okI, okJ := characterSortFields[key.field](charactar.Results[i], charactar.Results[j])
+			switch {
+			case !okI && !okJ:
+				continue
+			case !okI || !okJ:
+				return okI
+			case cmp == 0:
+				continue
+			case key.desc:
+				return cmp > 0
+			default:
+				return cmp < 0
+			}
+		}
+		return false
+	})
+
 	return charactar
 }
 
-func (app *application) filterBy(filter_value string, character Character ) (Character,  int) {
-	totalHeight := 0
-	var characters []Result
+// parseNumber reads SWAPI's numeric strings, which may carry thousands
+// separators ("1,358") or be "unknown".
+func parseNumber(value string) (float64, bool) {
+	x, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", ""), 64)
+	if err != nil {
+		return 0, false
+	}
+	return x, true
+}
+
+// parseBirthYear converts SWAPI birth years into a single timeline, where
+// years Before the Battle of Yavin are negative: "19BBY" is -19, "4ABY" is 4.
+func parseBirthYear(value string) (float64, bool) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+
+	sign := 1.0
+	switch {
+	case strings.HasSuffix(value, "BBY"):
+		sign = -1
+		value = strings.TrimSuffix(value, "BBY")
+	case strings.HasSuffix(value, "ABY"):
+		value = strings.TrimSuffix(value, "ABY")
+	default:
+		return 0, false
+	}
+
+	x, ok := parseNumber(value)
+	return sign * x, ok
+}
+
+func compareNumbers(a, b string) (int, bool, bool) {
+	x, okX := parseNumber(a)
+	y, okY := parseNumber(b)
+	return compareFloats(x, y), okX, okY
+}
+
+func compareFloats(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// characterFilter is one condition parsed from the query string, such as
+// height_gte=170 or gender=n/a,hermaphrodite.
+type characterFilter struct {
+	field  string
+	op     string
+	number float64
+	values []string
+}
+
+// characterNumberFields and characterTextFields list what characters can be
+// filtered on. Number fields take an optional _gt, _gte, _lt or _lte
+// suffix; text fields take a comma-separated list of accepted values.
+var characterNumberFields = map[string]func(r Result) (float64, bool){
+	"height":     func(r Result) (float64, bool) { return parseNumber(r.Height) },
+	"mass":       func(r Result) (float64, bool) { return parseNumber(r.Mass) },
+	"birth_year": func(r Result) (float64, bool) { return parseBirthYear(r.BirthYear) },
+}
+
+var characterTextFields = map[string]func(r Result) string{
+	"name":       func(r Result) string { return r.Name },
+	"gender":     func(r Result) string { return r.Gender },
+	"eye_color":  func(r Result) string { return r.EyeColor },
+	"hair_color": func(r Result) string { return r.HairColor },
+	"skin_color": func(r Result) string { return r.SkinColor },
+	"homeworld":  func(r Result) string { return r.Homeworld },
+}
+
+// characterQueryParams are the non-filter parameters /v1/characters accepts.
+var characterQueryParams = map[string]bool{
+	"page":      true,
+	"page_size": true,
+	"cursor":    true,
+	"sort":      true,
+	"units":     true,
+}
+
+// parseFilters turns every filter parameter in qs into a characterFilter.
+// Parameter names are case-insensitive. Unknown parameters and malformed
+// values are reported as errors rather than ignored.
+func (app *application) parseFilters(qs url.Values) ([]characterFilter, error) {
+	var filters []characterFilter
+
+	for key, values := range normalizeQuery(qs) {
+		if characterQueryParams[key] {
+			continue
+		}
+
+		value := strings.ToLower(strings.TrimSpace(strings.Join(values, ",")))
+		if value == "" {
+			continue
+		}
 
-		switch filter_value {
-			case "male":
-				
-				for i := range character.Results {
-					if character.Results[i].Gender == "male" {
-						characters = append(characters, character.Results[i])
-						x, _ := strconv.ParseInt(character.Results[i].Height, 10, 64)
-						totalHeight += int(x)
-					}
+		if _, ok := characterTextFields[key]; ok {
+			filter := characterFilter{field: key, op: "in"}
+			for _, v := range strings.Split(value, ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					filter.values = append(filter.values, v)
 				}
+			}
+			filters = append(filters, filter)
+			continue
+		}
+
+		field, op := key, "eq"
+		for _, suffix := range []string{"_gte", "_gt", "_lte", "_lt"} {
+			if strings.HasSuffix(key, suffix) {
+				field, op = strings.TrimSuffix(key, suffix), strings.TrimPrefix(suffix, "_")
+				break
+			}
+		}
+
+		if _, ok := characterNumberFields[field]; !ok {
+			return nil, fmt.Errorf("unknown filter %q", key)
+		}
+
+		number, ok := parseNumber(value)
+		if field == "birth_year" {
+			// accept both "19BBY" and a plain year on the BBY/ABY timeline
+			if year, isYear := parseBirthYear(value); isYear {
+				number, ok = year, true
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("filter %q must be a number", key)
+		}
+
+		filters = append(filters, characterFilter{field: field, op: op, number: number})
+	}
+
+	return filters, nil
+}
 
-			case "female":
-				for i := range character.Results {
-					if character.Results[i].Gender == "female" {
-						characters = append(characters, character.Results[i])
-						x, _ := strconv.ParseInt(character.Results[i].Height, 10, 64)
-						totalHeight += int(x)
-					}
+// match reports whether r satisfies the filter. Characters with an unknown
+// value for a number field never match a condition on it.
+func (f characterFilter) match(r Result) bool {
+	if f.op == "in" {
+		for _, have := range strings.Split(strings.ToLower(characterTextFields[f.field](r)), ",") {
+			have = strings.TrimSpace(have)
+			for _, want := range f.values {
+				if have == want {
+					return true
 				}
+			}
 		}
-	
-	character = Character{
-		Results: characters,
+		return false
+	}
+
+	x, ok := characterNumberFields[f.field](r)
+	if !ok {
+		return false
 	}
 
-	return character, totalHeight
+	switch f.op {
+	case "gt":
+		return x > f.number
+	case "gte":
+		return x >= f.number
+	case "lt":
+		return x < f.number
+	case "lte":
+		return x <= f.number
+	default:
+		return x == f.number
+	}
 }
 
+// filterBy keeps the characters that satisfy every filter.
+func (app *application) filterBy(filters []characterFilter, character Character) Character {
+	var characters []Result
+
+	for _, result := range character.Results {
+		matched := true
+		for _, filter := range filters {
+			if !filter.match(result) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			characters = append(characters, result)
+		}
+	}
+
+	return Character{
+		Results: characters,
+	}
+}
 
-func (app *application) findTotalHeight(totalHeight int, character Character) int {
+// findHeights returns the heights, in centimetres, of the characters whose
+// height is known.
+func (app *application) findHeights(character Character) []float64 {
+	heights := []float64{}
 	for i := range character.Results {
-		x, _ := strconv.ParseInt(character.Results[i].Height, 10, 64)
-		totalHeight += int(x)
+		if height, ok := parseNumber(character.Results[i].Height); ok {
+			heights = append(heights, height)
+		}
 	}
 
-	return totalHeight
-}
\ No newline at end of file
+	return heights
+}
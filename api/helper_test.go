@@ -0,0 +1,302 @@
+package api
+
+import (
+	"net/url"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestCharactersCacheKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{a: "", b: "page=2&page_size=5&units=imperial", same: true},
+		{a: "gender=male", b: "Gender=Male", same: true},
+		{a: "gender=male&sort=name", b: "sort=name&gender=male&page=3", same: true},
+		{a: "gender=male", b: "gender=female"},
+		{a: "sort=name", b: "sort=-name"},
+		{a: "gender=male", b: "sort=male"},
+	}
+
+	for _, tt := range tests {
+		qa := mustParseQuery(t, tt.a)
+		qb := mustParseQuery(t, tt.b)
+
+		if same := charactersCacheKey(qa) == charactersCacheKey(qb); same != tt.same {
+			t.Errorf("cache keys of %q and %q: same = %v, want %v", tt.a, tt.b, same, tt.same)
+		}
+	}
+
+	if key := charactersCacheKey(url.Values{}); key != "" {
+		t.Errorf("cache key of the unfiltered set = %q, want none", key)
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		value   string
+		want    []sortKey
+		wantErr bool
+	}{
+		{value: ""},
+		{value: "name", want: []sortKey{{field: "name"}}},
+		{value: "gender, -height,,birth_year", want: []sortKey{{field: "gender"}, {field: "height", desc: true}, {field: "birth_year"}}},
+		{value: "age", wantErr: true},
+		{value: "-", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := app.parseSort(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSort(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSort(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSortBy(t *testing.T) {
+	app := newTestApplication(t)
+
+	character := Character{Results: []Result{
+		{Name: "c", Height: "unknown", Gender: "male"},
+		{Name: "a", Height: "180", Gender: "male"},
+		{Name: "b", Height: "1,200", Gender: "female"},
+		{Name: "d", Height: "96", Gender: "male"},
+	}}
+
+	tests := []struct {
+		sort string
+		want []string
+	}{
+		{sort: "name", want: []string{"a", "b", "c", "d"}},
+		{sort: "-name", want: []string{"d", "c", "b", "a"}},
+		{sort: "height", want: []string{"d", "a", "b", "c"}},
+		{sort: "-height", want: []string{"b", "a", "d", "c"}},
+		{sort: "gender,-height", want: []string{"b", "a", "d", "c"}},
+	}
+
+	for _, tt := range tests {
+		keys, err := app.parseSort(tt.sort)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sorted := Character{Results: append([]Result(nil), character.Results...)}
+		sorted = app.sortBy(keys, sorted)
+
+		var got []string
+		for _, result := range sorted.Results {
+			got = append(got, result.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("sort=%s gives %v, want %v", tt.sort, got, tt.want)
+		}
+	}
+}
+
+func TestParseBirthYear(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+		ok    bool
+	}{
+		{value: "19BBY", want: -19, ok: true},
+		{value: "41.9bby", want: -41.9, ok: true},
+		{value: "4ABY", want: 4, ok: true},
+		{value: "unknown"},
+		{value: "19"},
+		{value: "BBY"},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseBirthYear(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseBirthYear(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseFilters(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		query   string
+		want    []characterFilter
+		wantErr bool
+	}{
+		{query: ""},
+		{query: "page=2&page_size=5&sort=name&units=imperial&cursor=x"},
+		{query: "gender=", want: nil},
+		{query: "gender=male", want: []characterFilter{{field: "gender", op: "in", values: []string{"male"}}}},
+		{query: "Gender=Male", want: []characterFilter{{field: "gender", op: "in", values: []string{"male"}}}},
+		{query: "gender=n/a,%20hermaphrodite", want: []characterFilter{{field: "gender", op: "in", values: []string{"n/a", "hermaphrodite"}}}},
+		{query: "height_gte=170", want: []characterFilter{{field: "height", op: "gte", number: 170}}},
+		{query: "mass_lt=1,358", want: []characterFilter{{field: "mass", op: "lt", number: 1358}}},
+		{query: "height=96", want: []characterFilter{{field: "height", op: "eq", number: 96}}},
+		{query: "birth_year_gt=19BBY", want: []characterFilter{{field: "birth_year", op: "gt", number: -19}}},
+		{query: "birth_year_lte=4", want: []characterFilter{{field: "birth_year", op: "lte", number: 4}}},
+		{query: "height_gte=tall", wantErr: true},
+		{query: "colour=red", wantErr: true},
+		{query: "name_gt=a", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := app.parseFilters(mustParseQuery(t, tt.query))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseFilters(%q) error = %v, want error %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		sort.Slice(got, func(i, j int) bool { return got[i].field < got[j].field })
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseFilters(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestCharacterFilterMatch(t *testing.T) {
+	luke := Result{Name: "Luke Skywalker", Height: "172", Mass: "77", Gender: "male", BirthYear: "19BBY", HairColor: "blond"}
+	jabba := Result{Name: "Jabba Desilijic Tiure", Height: "175", Mass: "1,358", Gender: "hermaphrodite", BirthYear: "600BBY", HairColor: "n/a"}
+	unknown := Result{Name: "Arvel Crynyd", Height: "unknown", Mass: "unknown", Gender: "male", BirthYear: "unknown"}
+
+	tests := []struct {
+		filter characterFilter
+		result Result
+		want   bool
+	}{
+		{filter: characterFilter{field: "gender", op: "in", values: []string{"male"}}, result: luke, want: true},
+		{filter: characterFilter{field: "gender", op: "in", values: []string{"female", "hermaphrodite"}}, result: jabba, want: true},
+		{filter: characterFilter{field: "gender", op: "in", values: []string{"female"}}, result: luke},
+		{filter: characterFilter{field: "name", op: "in", values: []string{"luke skywalker"}}, result: luke, want: true},
+		{filter: characterFilter{field: "height", op: "gte", number: 172}, result: luke, want: true},
+		{filter: characterFilter{field: "height", op: "gt", number: 172}, result: luke},
+		{filter: characterFilter{field: "mass", op: "gt", number: 1000}, result: jabba, want: true},
+		{filter: characterFilter{field: "birth_year", op: "lt", number: -100}, result: jabba, want: true},
+		{filter: characterFilter{field: "height", op: "lte", number: 1000}, result: unknown},
+		{filter: characterFilter{field: "height", op: "eq", number: 172}, result: luke, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.filter.match(tt.result); got != tt.want {
+			t.Errorf("%+v matches %s = %v, want %v", tt.filter, tt.result.Name, got, tt.want)
+		}
+	}
+}
+
+func TestToFeetInches(t *testing.T) {
+	tests := []struct {
+		cm   float64
+		want string
+	}{
+		{cm: 0, want: "0ft 0in"},
+		{cm: 172, want: "5ft 7.7in"},
+		{cm: 30.48, want: "1ft 0in"},
+	}
+
+	for _, tt := range tests {
+		if got := toFeetInches(tt.cm).String(); got != tt.want {
+			t.Errorf("toFeetInches(%v) = %s, want %s", tt.cm, got, tt.want)
+		}
+	}
+}
+
+func TestReadUnits(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{query: "", want: "metric"},
+		{query: "units=imperial", want: "imperial"},
+		{query: "units=Metric", want: "metric"},
+		{query: "units=furlongs", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := app.readUnits(mustParseQuery(t, tt.query))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("readUnits(%q) error = %v, want error %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("readUnits(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestCreateCharacterMetaData(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name    string
+		heights []string
+		units   string
+		want    HeightStats
+	}{
+		{
+			name:    "unknown heights are counted out",
+			heights: []string{"172", "unknown", "96", "202"},
+			units:   "metric",
+			want: HeightStats{
+				Units: "metric", Total: "470cm", Mean: "156.7cm", Known: 3,
+				TotalCm: 470, MinCm: 96, MaxCm: 202, MeanCm: 156.7, MedianCm: 172,
+				TotalImperial: FeetInches{Feet: 15, Inches: 5}, MeanImperial: FeetInches{Feet: 5, Inches: 1.7},
+			},
+		},
+		{
+			name:    "even count takes the middle pair for the median",
+			heights: []string{"180", "170", "160", "150"},
+			units:   "imperial",
+			want: HeightStats{
+				Units: "imperial", Total: "21ft 7.8in", Mean: "5ft 5in", Known: 4,
+				TotalCm: 660, MinCm: 150, MaxCm: 180, MeanCm: 165, MedianCm: 165,
+				TotalImperial: FeetInches{Feet: 21, Inches: 7.8}, MeanImperial: FeetInches{Feet: 5, Inches: 5},
+			},
+		},
+		{
+			name:    "no known heights",
+			heights: []string{"unknown"},
+			units:   "metric",
+			want:    HeightStats{Units: "metric", Total: "0cm", Mean: "0cm"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var character Character
+			for _, height := range tt.heights {
+				character.Results = append(character.Results, Result{Height: height})
+			}
+
+			metadata := app.createCharacterMetaData(character, tt.units)
+			if metadata.Count != len(tt.heights) {
+				t.Errorf("count = %d, want %d", metadata.Count, len(tt.heights))
+			}
+			if metadata.Height != tt.want {
+				t.Errorf("height = %+v, want %+v", metadata.Height, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"The Empire Strikes Back": "the-empire-strikes-back",
+		"  Return of the Jedi!! ": "return-of-the-jedi",
+		"Episode 4: A New Hope":   "episode-4-a-new-hope",
+	}
+
+	for in, want := range tests {
+		if got := slugify(in); got != want {
+			t.Errorf("slugify(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
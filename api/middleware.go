@@ -0,0 +1,28 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// requireAdmin only lets requests through that carry the configured
+// ADMIN_TOKEN as a bearer token. Without a configured token the admin
+// routes are switched off entirely.
+func (app *application) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if app.config.admin.token == "" {
+			app.notFoundResponse(w, r)
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(app.config.admin.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/JacobNewton007/busha-test/internals/data"
+)
+
+// moderationQueueLimit caps how many comments one queue request returns.
+const moderationQueueLimit = 100
+
+// moderator names the admin making a change, from the X-Moderator header.
+func moderator(r *http.Request) string {
+	name := strings.TrimSpace(r.Header.Get("X-Moderator"))
+	if name == "" {
+		return "admin"
+	}
+	return name
+}
+
+func (app *application) ModerationQueueHandler(w http.ResponseWriter, r *http.Request) {
+	status := app.readString(r.URL.Query(), "status", data.StatusPending)
+
+	switch status {
+	case data.StatusPending, data.StatusHidden, data.StatusDeleted:
+	default:
+		app.badRequestResponse(w, r, fmt.Errorf("status must be one of %s, %s, %s", data.StatusPending, data.StatusHidden, data.StatusDeleted))
+		return
+	}
+
+	comments, err := app.models.Comments.ModerationQueue(status, moderationQueueLimit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"comments": comments, "message": "fetch moderation queue successfully", "status": "success"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// moderateCommentHandler returns a handler that moves a comment from one of
+// the from statuses to status.
+func (app *application) moderateCommentHandler(from []string, status string, message string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := app.readIDParam(r)
+		if err != nil {
+			app.notFoundResponse(w, r)
+			return
+		}
+
+		comment, err := app.models.Comments.GetAny(id)
+		if err != nil {
+			app.recordErrorResponse(w, r, err)
+			return
+		}
+
+		expected, ok, err := app.readExpectedVersion(r)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		if ok && expected != comment.Version {
+			app.editConflictResponse(w, r)
+			return
+		}
+
+		err = app.models.Comments.Moderate(comment, from, status, moderator(r))
+		if err != nil {
+			app.recordErrorResponse(w, r, err)
+			return
+		}
+
+		// the movie list caches comment counts, which only include visible comments
+		app.cache.Expire(r.Context(), "movies", time.Second)
+
+		err = app.writeJSON(w, http.StatusOK, envelope{"comment": comment, "message": message, "status": "success"}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/JacobNewton007/busha-test/internals/data"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		status        int
+	}{
+		{name: "admin routes switched off", authorization: "Bearer secret", status: http.StatusNotFound},
+		{name: "missing token", token: "secret", status: http.StatusUnauthorized},
+		{name: "wrong token", token: "secret", authorization: "Bearer guess", status: http.StatusUnauthorized},
+		{name: "token without the bearer scheme", token: "secret", authorization: "secret", status: http.StatusOK},
+		{name: "admin", token: "secret", authorization: "Bearer secret", status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.admin.token = tt.token
+
+			next := func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/admin/comments", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			rr := httptest.NewRecorder()
+
+			app.requireAdmin(next).ServeHTTP(rr, r)
+			if rr.Code != tt.status {
+				t.Errorf("status %d, want %d", rr.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestModerationQueueHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		status string
+		want   int
+	}{
+		{name: "pending by default", status: data.StatusPending, want: http.StatusOK},
+		{name: "deleted", query: "?status=deleted", status: data.StatusDeleted, want: http.StatusOK},
+		{name: "visible isn't a queue", query: "?status=visible", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.admin.token = "secret"
+			mock := mockModels(t, app)
+
+			if tt.status != "" {
+				mock.ExpectQuery(regexp.QuoteMeta("WHERE status = $1")).
+					WithArgs(tt.status, moderationQueueLimit).
+					WillReturnRows(commentRows(
+						&data.Comment{ID: 1, Comment: "Great film", Movie: "A New Hope", CommenterIp: "203.0.113.7", Version: 1, Status: tt.status},
+					))
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/admin/comments"+tt.query, nil)
+			r.Header.Set("Authorization", "Bearer secret")
+			rr := httptest.NewRecorder()
+
+			app.routes().ServeHTTP(rr, r)
+			if rr.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", rr.Code, tt.want, rr.Body)
+			}
+		})
+	}
+}
+
+func TestModerator(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/v1/admin/comments/1/hide", nil)
+	if got := moderator(r); got != "admin" {
+		t.Errorf("moderator() = %q without X-Moderator, want admin", got)
+	}
+
+	r.Header.Set("X-Moderator", " obi-wan ")
+	if got := moderator(r); got != "obi-wan" {
+		t.Errorf("moderator() = %q, want obi-wan", got)
+	}
+}
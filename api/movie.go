@@ -1,89 +1,426 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/JacobNewton007/busha-test/internals/data"
+	"github.com/JacobNewton007/busha-test/internals/swapi"
 )
 
 type Data struct {
-	Title        string `json:"title"`
-	OpeningCrawl string `json:"opening_crawl"`
-	ReleaseDate  string `json:"release_date"`
+	Title        string    `json:"title"`
+	EpisodeID    int       `json:"episode_id"`
+	Slug         string    `json:"slug"`
+	OpeningCrawl string    `json:"opening_crawl"`
+	ReleaseDate  string    `json:"release_date"`
 	Date         time.Time `json:"-"`
-	CommentCount int `json:"comment_count"`
+	CommentCount int       `json:"comment_count"`
+	Links        filmLinks `json:"links"`
 }
 type Movie struct {
 	Results []Data `json:"results"`
 }
 
 func (app *application) GetMovieHandler(w http.ResponseWriter, r *http.Request) {
-	var rdb = app.client
 	var movie Movie
-	movies, _ := rdb.Get(Ctx, "movies").Result()
+	err := app.cacheAside(r.Context(), "movies", &movie, func(ctx context.Context) (interface{}, error) {
+		return app.loadMovies(ctx)
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movie.Results, "message": "fetch movies successfully", "status": "success"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// loadMovies builds the film list from the catalogue, oldest release
+// first, with each film's comment count.
+func (app *application) loadMovies(ctx context.Context) (Movie, error) {
+	var movie Movie
+
+	films, err := app.catalogueFilms(ctx)
+	if err != nil {
+		return movie, err
+	}
+
+	for _, film := range films {
+		movie.Results = append(movie.Results, Data{
+			Title:        film.Title,
+			EpisodeID:    film.EpisodeID,
+			Slug:         slugify(film.Title),
+			OpeningCrawl: film.OpeningCrawl,
+			ReleaseDate:  film.ReleaseDate,
+			Links:        newFilmLinks(film),
+		})
+	}
+
+	movies := make([]data.CommentMovie, len(movie.Results))
+	for i := range movie.Results {
+		movies[i] = data.CommentMovie{EpisodeID: movie.Results[i].EpisodeID, Title: movie.Results[i].Title}
+	}
+
+	counts, err := app.models.Comments.CountByMovies(movies)
+	if err != nil {
+		return movie, err
+	}
 
-	if movies != "" {
-		data := Movie{}
-    json.Unmarshal([]byte(movies), &data)
-  
-		err := app.writeJSON(w, http.StatusOK, envelope{"movies": data.Results}, nil)
+	for i := range movie.Results {
+		date, err := time.Parse("2006-01-02", movie.Results[i].ReleaseDate)
 		if err != nil {
-			app.serverErrorResponse(w, r, err)
-			return
+			return movie, err
+		}
+
+		movie.Results[i].Date = date
+		movie.Results[i].CommentCount = counts[movie.Results[i].EpisodeID]
+	}
+
+	sort.Slice(movie.Results, func(i, j int) bool { return movie.Results[i].Date.Before(movie.Results[j].Date) })
+
+	return movie, nil
+}
+
+// filmLinks are the canonical urls of a film and the resources nested
+// under it. They use the film's slug, though every route also accepts the
+// episode id or the title.
+type filmLinks struct {
+	Self       string `json:"self"`
+	Comments   string `json:"comments"`
+	Characters string `json:"characters"`
+}
+
+func newFilmLinks(film swapi.Film) filmLinks {
+	slug := slugify(film.Title)
+	return filmLinks{
+		Self:       "/v1/movies/" + slug,
+		Comments:   "/v1/comments/" + slug,
+		Characters: "/v1/movies/" + slug + "/characters",
+	}
+}
+
+// latestCommentsLimit is how many of a film's newest comments are embedded
+// in the single film response.
+const latestCommentsLimit = 5
+
+// filmExpansions are the SWAPI url lists GET /v1/movies/:id can resolve
+// into embedded objects through ?expand=.
+var filmExpansions = []string{"characters", "planets", "starships", "vehicles", "species"}
+
+// MovieDetail is a single film. The resource lists hold SWAPI urls unless
+// they were expanded, in which case they hold the resolved objects.
+type MovieDetail struct {
+	Title        string          `json:"title"`
+	EpisodeID    int             `json:"episode_id"`
+	Slug         string          `json:"slug"`
+	OpeningCrawl string          `json:"opening_crawl"`
+	Director     string          `json:"director"`
+	Producer     string          `json:"producer"`
+	ReleaseDate  string          `json:"release_date"`
+	Characters   interface{}     `json:"characters"`
+	Planets      interface{}     `json:"planets"`
+	Starships    interface{}     `json:"starships"`
+	Vehicles     interface{}     `json:"vehicles"`
+	Species      interface{}     `json:"species"`
+	CommentCount int             `json:"comment_count"`
+	Comments     []*data.Comment `json:"comments"`
+	Links        filmLinks       `json:"links"`
+}
+
+// films returns the film catalogue: read from Postgres when that is the
+// catalogue source, otherwise from SWAPI, cached for two hours.
+func (app *application) films(ctx context.Context) ([]swapi.Film, error) {
+	if app.fromPostgres() {
+		return app.catalogueFilms(ctx)
+	}
+
+	var films []swapi.Film
+	err := app.cacheAside(ctx, "swapi:films", &films, func(ctx context.Context) (interface{}, error) {
+		return app.swapi.Films(ctx)
+	})
+	return films, err
+}
+
+// findFilm looks a film up by episode id or by its title's slug, so "5",
+// "the-empire-strikes-back" and "The Empire Strikes Back" all find the same
+// film.
+func (app *application) findFilm(ctx context.Context, id string) (swapi.Film, error) {
+	films, err := app.films(ctx)
+	if err != nil {
+		return swapi.Film{}, err
+	}
+
+	episode, err := strconv.Atoi(id)
+	for _, film := range films {
+		if err == nil && film.EpisodeID == episode {
+			return film, nil
 		}
+		if slugify(film.Title) == slugify(id) {
+			return film, nil
+		}
+	}
+
+	return swapi.Film{}, swapi.ErrNotFound
+}
+
+// resolveResources fetches every SWAPI url in urls, at most
+// swapiPageWorkers at a time, keeping the order of urls.
+func resolveResources[T any](ctx context.Context, app *application, urls []string) ([]T, error) {
+	resources := make([]T, len(urls))
+	errs := make([]error, len(urls))
 
-	} else {
-		const url = "https://swapi.dev/api/films"
+	sem := make(chan struct{}, swapiPageWorkers)
+	var wg sync.WaitGroup
 
-		body, err := app.fetchUrl(url)
+	for i, resourceURL := range urls {
+		wg.Add(1)
+		go func(i int, resourceURL string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			errs[i] = app.fetchResource(ctx, resourceURL, &resources[i])
+		}(i, resourceURL)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
 		if err != nil {
-			app.serverErrorResponse(w, r, err)
-			return
+			return nil, err
 		}
+	}
 
-		json.Unmarshal(body, &movie)
+	return resources, nil
+}
 
-		for i := range movie.Results {
-			date, err := time.Parse("2006-01-02", movie.Results[i].ReleaseDate)
+// fetchResource resolves a single SWAPI url into dst, caching it by path.
+func (app *application) fetchResource(ctx context.Context, resourceURL string, dst interface{}) error {
+	path, err := swapi.ResourcePath(resourceURL)
+	if err != nil {
+		return err
+	}
 
-			if err != nil {
-				app.serverErrorResponse(w, r, err)
-				return
-			}
+	return app.cacheAside(ctx, "swapi:"+path, dst, func(ctx context.Context) (interface{}, error) {
+		// load into a value of our own, dst belongs to the request that
+		// triggered the load and may be gone by the time it finishes
+		var raw json.RawMessage
+		err := app.swapi.Get(ctx, resourceURL, &raw)
+		return raw, err
+	})
+}
 
-			movie.Results[i].Date = date
-			_, totalRecords, err := app.models.Comments.GetCommentForMovie(movie.Results[i].Title)
+func (app *application) readExpand(qs url.Values) (map[string]bool, error) {
+	expand := map[string]bool{}
+
+	for _, field := range strings.Split(app.readString(qs, "expand", ""), ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if field == "" {
+			continue
+		}
+
+		valid := false
+		for _, allowed := range filmExpansions {
+			valid = valid || field == allowed
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid expand field %q, expand must be a comma-separated list of %s", field, strings.Join(filmExpansions, ", "))
+		}
+
+		expand[field] = true
+	}
+
+	return expand, nil
+}
+
+// expandFilm fills in the resource lists of movie, resolving those named
+// in expand concurrently and leaving the rest as SWAPI urls.
+func (app *application) expandFilm(ctx context.Context, movie *MovieDetail, film swapi.Film, expand map[string]bool) error {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+
+	resolve := func(field string, urls []string, dst *interface{}, fetch func() (interface{}, error)) {
+		*dst = urls
+		if !expand[field] {
+			return
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resources, err := fetch()
+
+			mu.Lock()
+			defer mu.Unlock()
 			if err != nil {
-				app.notFoundResponse(w, r)
+				if firstErr == nil {
+					firstErr = err
+				}
 				return
 			}
+			*dst = resources
+		}()
+	}
 
-			movie.Results[i].CommentCount = totalRecords
-		}
+	resolve("characters", film.Characters, &movie.Characters, func() (interface{}, error) {
+		return resolveResources[swapi.Person](ctx, app, film.Characters)
+	})
+	resolve("planets", film.Planets, &movie.Planets, func() (interface{}, error) {
+		return resolveResources[swapi.Planet](ctx, app, film.Planets)
+	})
+	resolve("starships", film.Starships, &movie.Starships, func() (interface{}, error) {
+		return resolveResources[swapi.Starship](ctx, app, film.Starships)
+	})
+	resolve("vehicles", film.Vehicles, &movie.Vehicles, func() (interface{}, error) {
+		return resolveResources[swapi.Vehicle](ctx, app, film.Vehicles)
+	})
+	resolve("species", film.Species, &movie.Species, func() (interface{}, error) {
+		return resolveResources[swapi.Species](ctx, app, film.Species)
+	})
 
-		sort.Slice(movie.Results, func(i, j int) bool { return movie.Results[i].Date.Before(movie.Results[j].Date) })
-		data, err := json.Marshal(movie)
+	wg.Wait()
+	return firstErr
+}
 
-		if err != nil {
-			app.serverErrorResponse(w, r, err)
-			return
+func (app *application) ShowMovieHandler(w http.ResponseWriter, r *http.Request) {
+	expand, err := app.readExpand(r.URL.Query())
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	film, err := app.findFilm(r.Context(), app.readMovieIDParams(r))
+	if err != nil {
+		app.swapiErrorResponse(w, r, err)
+		return
+	}
+
+	movie := MovieDetail{
+		Title:        film.Title,
+		EpisodeID:    film.EpisodeID,
+		Slug:         slugify(film.Title),
+		OpeningCrawl: film.OpeningCrawl,
+		Director:     film.Director,
+		Producer:     film.Producer,
+		ReleaseDate:  film.ReleaseDate,
+		Links:        newFilmLinks(film),
+	}
+
+	err = app.expandFilm(r.Context(), &movie, film, expand)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	comments, _, err := app.models.Comments.GetCommentForMovie(commentMovie(film), data.CommentFilters{Limit: latestCommentsLimit, Sort: "-created_at"})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	counts, err := app.models.Comments.CountByMovies([]data.CommentMovie{commentMovie(film)})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	movie.Comments = comments
+	movie.CommentCount = counts[film.EpisodeID]
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie, "message": "fetch movie successfully", "status": "success"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// filmCharacters resolves the cast of film, along with each character's
+// homeworld name, from their SWAPI urls.
+func (app *application) filmCharacters(ctx context.Context, film swapi.Film) (Character, error) {
+	people, err := resolveResources[swapi.Person](ctx, app, film.Characters)
+	if err != nil {
+		return Character{}, err
+	}
+
+	var homeworlds []string
+	seen := map[string]bool{}
+	for _, person := range people {
+		if person.Homeworld != "" && !seen[person.Homeworld] {
+			seen[person.Homeworld] = true
+			homeworlds = append(homeworlds, person.Homeworld)
 		}
+	}
+
+	planets, err := resolveResources[swapi.Planet](ctx, app, homeworlds)
+	if err != nil {
+		return Character{}, err
+	}
 
-		err = rdb.Set(Ctx, "movies", data, time.Hour*2).Err()
+	planetNames := make(map[string]string, len(planets))
+	for i, planet := range planets {
+		planetNames[homeworlds[i]] = planet.Name
+	}
+
+	return newCharacter(people, planetNames), nil
+}
+
+func (app *application) MovieCharactersHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	q, err := app.readCharacterQuery(qs)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	film, err := app.findFilm(r.Context(), app.readMovieIDParams(r))
+	if err != nil {
+		app.swapiErrorResponse(w, r, err)
+		return
+	}
 
+	if app.fromPostgres() {
+		filmID, err := swapi.ResourceID(film.URL)
 		if err != nil {
 			app.serverErrorResponse(w, r, err)
 			return
 		}
 
-		err = app.writeJSON(w, http.StatusOK, envelope{"movies": movie.Results, "message": "fetch movies successfully", "status": "success"}, nil)
-
+		err = app.writeCatalogueCharacters(w, r, q, filmID, newFilmLinks(film))
 		if err != nil {
 			app.serverErrorResponse(w, r, err)
-			return
 		}
+		return
+	}
 
+	load := func(ctx context.Context) (Character, error) {
+		return app.filmCharacters(ctx, film)
 	}
 
+	prefix := fmt.Sprintf("films:%d:characters", film.EpisodeID)
+	character, err := app.queryCharacters(r.Context(), prefix, qs, load, q)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeCharacters(w, r, q.page, character, q.units, newFilmLinks(film))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 }
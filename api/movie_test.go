@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/JacobNewton007/busha-test/internals/swapi"
+)
+
+func TestReadExpand(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		query   string
+		want    map[string]bool
+		wantErr bool
+	}{
+		{query: "", want: map[string]bool{}},
+		{query: "expand=characters", want: map[string]bool{"characters": true}},
+		{query: "expand=Planets,%20starships,,species", want: map[string]bool{"planets": true, "starships": true, "species": true}},
+		{query: "expand=characters,vehicles,species,planets,starships", want: map[string]bool{"characters": true, "vehicles": true, "species": true, "planets": true, "starships": true}},
+		{query: "expand=comments", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := app.readExpand(mustParseQuery(t, tt.query))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("readExpand(%q) error = %v, want error %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("readExpand(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestFindFilm(t *testing.T) {
+	app := newTestApplication(t)
+
+	for _, id := range []string{"5", "the-empire-strikes-back", "The Empire Strikes Back", "the empire STRIKES back"} {
+		film, err := app.findFilm(context.Background(), id)
+		if err != nil {
+			t.Errorf("findFilm(%q): %v", id, err)
+			continue
+		}
+		if film.EpisodeID != 5 {
+			t.Errorf("findFilm(%q) = episode %d, want 5", id, film.EpisodeID)
+		}
+	}
+
+	for _, id := range []string{"42", "empire", ""} {
+		_, err := app.findFilm(context.Background(), id)
+		if !errors.Is(err, swapi.ErrNotFound) {
+			t.Errorf("findFilm(%q) err = %v, want swapi.ErrNotFound", id, err)
+		}
+	}
+}
+
+func TestNewFilmLinks(t *testing.T) {
+	got := newFilmLinks(swapi.Film{Title: "The Empire Strikes Back", EpisodeID: 5})
+
+	want := filmLinks{
+		Self:       "/v1/movies/the-empire-strikes-back",
+		Comments:   "/v1/comments/the-empire-strikes-back",
+		Characters: "/v1/movies/the-empire-strikes-back/characters",
+	}
+	if got != want {
+		t.Errorf("newFilmLinks() = %+v, want %+v", got, want)
+	}
+}
+
+func TestGetMovieHandler(t *testing.T) {
+	app := newTestApplication(t)
+	mock := mockModels(t, app)
+	routes := app.routes()
+
+	// only the first request reaches Postgres; the second is cached
+	mock.ExpectQuery(regexp.QuoteMeta("FROM unnest($1::bigint[], $2::text[])")).
+		WillReturnRows(sqlmock.NewRows([]string{"episode_id", "count"}).AddRow(4, 2))
+
+	for i := 0; i < 2; i++ {
+		status, body := get(t, routes, "/v1/movies")
+		if status != http.StatusOK {
+			t.Fatalf("request %d: status %d, want %d", i, status, http.StatusOK)
+		}
+
+		var movies []Data
+		if err := json.Unmarshal(body["movies"], &movies); err != nil {
+			t.Fatal(err)
+		}
+		if len(movies) != 6 || movies[0].Title != "A New Hope" || movies[5].EpisodeID != 3 {
+			t.Fatalf("request %d: movies %+v, want all 6 by release date", i, movies)
+		}
+		if movies[0].CommentCount != 2 || movies[1].CommentCount != 0 {
+			t.Errorf("request %d: comment counts %d and %d, want 2 and 0", i, movies[0].CommentCount, movies[1].CommentCount)
+		}
+	}
+}
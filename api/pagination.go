@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// pagination is the window of a result set a client asked for, either by
+// page number or by an opaque cursor handed out in a previous response.
+type pagination struct {
+	Offset   int
+	PageSize int
+}
+
+func (app *application) readPagination(qs url.Values) (pagination, error) {
+	p := pagination{PageSize: defaultPageSize}
+
+	if qs.Get("page_size") != "" {
+		size, err := strconv.Atoi(qs.Get("page_size"))
+		if err != nil || size < 1 || size > maxPageSize {
+			return p, fmt.Errorf("page_size must be an integer between 1 and %d", maxPageSize)
+		}
+		p.PageSize = size
+	}
+
+	if cursor := qs.Get("cursor"); cursor != "" {
+		offset, err := decodeCursor(cursor)
+		if err != nil {
+			return p, err
+		}
+		p.Offset = offset
+		return p, nil
+	}
+
+	if qs.Get("page") != "" {
+		page, err := strconv.Atoi(qs.Get("page"))
+		if err != nil || page < 1 {
+			return p, errors.New("page must be a positive integer")
+		}
+		// a page whose offset doesn't fit in an int is past any result set
+		if page > math.MaxInt/p.PageSize {
+			return p, fmt.Errorf("page must be at most %d for a page_size of %d", math.MaxInt/p.PageSize, p.PageSize)
+		}
+		p.Offset = (page - 1) * p.PageSize
+	}
+
+	return p, nil
+}
+
+// window returns the slice bounds of the requested page in a result set of
+// total items, both within [0, total].
+func (p pagination) window(total int) (int, int) {
+	start := p.Offset
+	if start < 0 {
+		start = 0
+	}
+	if start > total {
+		start = total
+	}
+
+	end := start + p.PageSize
+	if end > total {
+		end = total
+	}
+
+	return start, end
+}
+
+func (p pagination) page() int {
+	return p.Offset/p.PageSize + 1
+}
+
+func pageCount(total, pageSize int) int {
+	return (total + pageSize - 1) / pageSize
+}
+
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	errInvalid := errors.New("cursor is invalid")
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errInvalid
+	}
+
+	if !strings.HasPrefix(string(raw), "offset:") {
+		return 0, errInvalid
+	}
+
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), "offset:"))
+	if err != nil || offset < 0 {
+		return 0, errInvalid
+	}
+
+	return offset, nil
+}
+
+// cursorLink rebuilds the request url with the page selector replaced by a
+// cursor pointing at offset, keeping every other query parameter.
+func cursorLink(r *http.Request, p pagination, offset int) string {
+	qs := r.URL.Query()
+	qs.Del("page")
+	qs.Set("cursor", encodeCursor(offset))
+	qs.Set("page_size", strconv.Itoa(p.PageSize))
+
+	link := url.URL{Path: r.URL.Path, RawQuery: qs.Encode()}
+	return link.String()
+}
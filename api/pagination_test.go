@@ -0,0 +1,121 @@
+package api
+
+import (
+	"math"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestReadPagination(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		query   string
+		want    pagination
+		wantErr bool
+	}{
+		{query: "", want: pagination{Offset: 0, PageSize: defaultPageSize}},
+		{query: "page=3&page_size=10", want: pagination{Offset: 20, PageSize: 10}},
+		{query: "page_size=100", want: pagination{PageSize: 100}},
+		{query: "cursor=" + encodeCursor(40) + "&page=2", want: pagination{Offset: 40, PageSize: defaultPageSize}},
+		{query: "page=0", wantErr: true},
+		{query: "page=x", wantErr: true},
+		{query: "page_size=0", wantErr: true},
+		{query: "page_size=101", wantErr: true},
+		{query: "page=461168601842738792", wantErr: true},
+		{query: "page=9223372036854775807&page_size=1", want: pagination{Offset: 9223372036854775806, PageSize: 1}},
+		{query: "page=4611686018427387904&page_size=2", wantErr: true},
+		{query: "page=9223372036854775808", wantErr: true},
+		{query: "page=92233720368547758&page_size=100", want: pagination{Offset: 9223372036854775700, PageSize: 100}},
+		{query: "cursor=bad!", wantErr: true},
+		{query: "cursor=" + url.QueryEscape(encodeCursor(-1)), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		qs, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := app.readPagination(qs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("readPagination(%q) error = %v, want error %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("readPagination(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	tests := []struct {
+		cursor  string
+		want    int
+		wantErr bool
+	}{
+		{cursor: encodeCursor(0), want: 0},
+		{cursor: encodeCursor(120), want: 120},
+		{cursor: "", wantErr: true},
+		{cursor: "not base64!", wantErr: true},
+		{cursor: "b2Zmc2V0Og", wantErr: true},   // "offset:"
+		{cursor: "cGFnZToy", wantErr: true},     // "page:2"
+		{cursor: "b2Zmc2V0Oi01", wantErr: true}, // "offset:-5"
+	}
+
+	for _, tt := range tests {
+		got, err := decodeCursor(tt.cursor)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("decodeCursor(%q) error = %v, want error %v", tt.cursor, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("decodeCursor(%q) = %d, want %d", tt.cursor, got, tt.want)
+		}
+	}
+}
+
+func TestPaginationWindow(t *testing.T) {
+	tests := []struct {
+		p          pagination
+		total      int
+		start, end int
+		page       int
+	}{
+		{p: pagination{Offset: 0, PageSize: 10}, total: 27, start: 0, end: 10, page: 1},
+		{p: pagination{Offset: 20, PageSize: 10}, total: 27, start: 20, end: 27, page: 3},
+		{p: pagination{Offset: 40, PageSize: 10}, total: 27, start: 27, end: 27, page: 5},
+		{p: pagination{Offset: 0, PageSize: 10}, total: 0, start: 0, end: 0, page: 1},
+		{p: pagination{Offset: -10, PageSize: 10}, total: 27, start: 0, end: 10, page: 0},
+		{p: pagination{Offset: math.MaxInt, PageSize: 10}, total: 27, start: 27, end: 27, page: math.MaxInt/10 + 1},
+	}
+
+	for _, tt := range tests {
+		start, end := tt.p.window(tt.total)
+		if start != tt.start || end != tt.end || tt.p.page() != tt.page {
+			t.Errorf("%+v of %d: window %d-%d page %d, want %d-%d page %d", tt.p, tt.total, start, end, tt.p.page(), tt.start, tt.end, tt.page)
+		}
+	}
+
+	if got := pageCount(27, 10); got != 3 {
+		t.Errorf("pageCount(27, 10) = %d, want 3", got)
+	}
+}
+
+func TestCursorLink(t *testing.T) {
+	r := httptest.NewRequest("GET", "/v1/characters?page=2&gender=male&sort=-height", nil)
+
+	link, err := url.Parse(cursorLink(r, pagination{Offset: 10, PageSize: 10}, 20))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qs := link.Query()
+	if link.Path != "/v1/characters" || qs.Get("page") != "" || qs.Get("page_size") != "10" || qs.Get("gender") != "male" || qs.Get("sort") != "-height" {
+		t.Errorf("cursorLink = %s", link)
+	}
+	if offset, err := decodeCursor(qs.Get("cursor")); err != nil || offset != 20 {
+		t.Errorf("cursorLink cursor decodes to %d, %v, want 20", offset, err)
+	}
+}
@@ -0,0 +1,363 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// rateLimit is a budget of limit requests per sliding window.
+type rateLimit struct {
+	limit  int
+	window time.Duration
+}
+
+// rateLimitRule gives the requests matching method and pattern their own
+// budget. Patterns use the router's syntax, so ":movie_name" matches any
+// single path segment.
+type rateLimitRule struct {
+	method  string
+	pattern string
+	rateLimit
+}
+
+func (rule rateLimitRule) name() string {
+	return rule.method + " " + rule.pattern
+}
+
+// match reports whether r is a request for the rule's route. Paths are
+// compared case-insensitively, like the router's redirects.
+func (rule rateLimitRule) match(r *http.Request) bool {
+	if rule.method != r.Method {
+		return false
+	}
+
+	want := strings.Split(strings.Trim(rule.pattern, "/"), "/")
+	got := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(want) != len(got) {
+		return false
+	}
+
+	for i := range want {
+		if strings.HasPrefix(want[i], ":") {
+			if got[i] == "" {
+				return false
+			}
+			continue
+		}
+		if !strings.EqualFold(want[i], got[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseRateLimit parses a budget written as "limit/window", e.g. "60/1m".
+func parseRateLimit(value string) (rateLimit, error) {
+	limit, window, found := strings.Cut(strings.TrimSpace(value), "/")
+	if !found {
+		return rateLimit{}, fmt.Errorf("invalid rate limit %q, expected limit/window such as 60/1m", value)
+	}
+
+	var rl rateLimit
+	var err error
+
+	rl.limit, err = strconv.Atoi(limit)
+	if err != nil || rl.limit < 1 {
+		return rateLimit{}, fmt.Errorf("invalid rate limit %q, limit must be a positive integer", value)
+	}
+
+	rl.window, err = time.ParseDuration(window)
+	if err != nil || rl.window <= 0 {
+		return rateLimit{}, fmt.Errorf("invalid rate limit %q, window must be a positive duration", value)
+	}
+
+	return rl, nil
+}
+
+// parseRateLimitRules parses a comma-separated list of per-route budgets
+// such as "POST /v1/comments/:movie_name=10/1m,GET /v1/characters=30/1m".
+func parseRateLimitRules(spec string) ([]rateLimitRule, error) {
+	var rules []rateLimitRule
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		route, budget, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid rate limit rule %q, expected METHOD /path=limit/window", entry)
+		}
+
+		fields := strings.Fields(route)
+		if len(fields) != 2 || !strings.HasPrefix(fields[1], "/") {
+			return nil, fmt.Errorf("invalid rate limit rule %q, expected METHOD /path=limit/window", entry)
+		}
+
+		rl, err := parseRateLimit(budget)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, rateLimitRule{method: strings.ToUpper(fields[0]), pattern: fields[1], rateLimit: rl})
+	}
+
+	return rules, nil
+}
+
+// limitResult is the outcome of counting one request against a budget.
+type limitResult struct {
+	allowed   bool
+	remaining int
+	reset     time.Time
+}
+
+// limiter counts a request against the budget stored under key.
+type limiter interface {
+	allow(ctx context.Context, key string, rl rateLimit, now time.Time) (limitResult, error)
+}
+
+// slidingWindowScript keeps one sorted set entry per allowed request,
+// scored by its time in milliseconds. Entries older than the window are
+// dropped before counting, so the budget slides with every request rather
+// than resetting on fixed boundaries. Rejected requests are not recorded.
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', key, window)
+
+local reset = now + window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window
+end
+
+return {allowed, count, reset}
+`)
+
+// redisLimiter shares budgets between every instance of the api.
+type redisLimiter struct {
+	client *redis.Client
+}
+
+func (l *redisLimiter) allow(ctx context.Context, key string, rl rateLimit, now time.Time) (limitResult, error) {
+	id := make([]byte, 8)
+	_, err := rand.Read(id)
+	if err != nil {
+		return limitResult{}, err
+	}
+
+	args := []interface{}{now.UnixMilli(), rl.window.Milliseconds(), rl.limit, fmt.Sprintf("%d-%s", now.UnixNano(), hex.EncodeToString(id))}
+
+	values, err := slidingWindowScript.Run(ctx, l.client, []string{key}, args...).Int64Slice()
+	if err != nil {
+		return limitResult{}, err
+	}
+	if len(values) != 3 {
+		return limitResult{}, fmt.Errorf("rate limit script returned %d values", len(values))
+	}
+
+	return limitResult{
+		allowed:   values[0] == 1,
+		remaining: rl.limit - int(values[1]),
+		reset:     time.UnixMilli(values[2]),
+	}, nil
+}
+
+// memoryLimiter is the same sliding window kept in process memory. Budgets
+// are per instance, which is good enough while redis is down.
+type memoryLimiter struct {
+	mu     sync.Mutex
+	hits   map[string][]time.Time
+	swept  time.Time
+	window time.Duration
+}
+
+func newMemoryLimiter() *memoryLimiter {
+	return &memoryLimiter{hits: map[string][]time.Time{}}
+}
+
+func (l *memoryLimiter) allow(ctx context.Context, key string, rl rateLimit, now time.Time) (limitResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if rl.window > l.window {
+		l.window = rl.window
+	}
+	l.sweep(now)
+
+	hits := l.hits[key]
+	start := now.Add(-rl.window)
+	i := 0
+	for i < len(hits) && !hits[i].After(start) {
+		i++
+	}
+	hits = hits[i:]
+
+	result := limitResult{allowed: len(hits) < rl.limit}
+	if result.allowed {
+		hits = append(hits, now)
+	}
+	l.hits[key] = hits
+
+	// hits can't be empty here: a rejected request means the budget is used
+	result.remaining = rl.limit - len(hits)
+	result.reset = hits[0].Add(rl.window)
+
+	return result, nil
+}
+
+// sweep forgets clients that have been quiet for longer than the widest
+// window seen, at most once per window, so the map doesn't grow forever.
+func (l *memoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.window {
+		return
+	}
+	l.swept = now
+
+	for key, hits := range l.hits {
+		if len(hits) == 0 || now.Sub(hits[len(hits)-1]) > l.window {
+			delete(l.hits, key)
+		}
+	}
+}
+
+// redisRetryInterval is how often a degraded rate limiter tries redis
+// again. Requests in between go straight to memory rather than each
+// waiting on redis to time out.
+const redisRetryInterval = 5 * time.Second
+
+// rateLimiter counts requests in redis and falls back to memory whenever
+// redis can't be reached.
+type rateLimiter struct {
+	primary  limiter
+	fallback limiter
+	degraded int32
+	// retryAt is when, in unix nanoseconds, a degraded limiter next
+	// tries redis
+	retryAt int64
+}
+
+func newRateLimiter(client *redis.Client) *rateLimiter {
+	rl := &rateLimiter{fallback: newMemoryLimiter()}
+	if client != nil {
+		rl.primary = &redisLimiter{client: client}
+	}
+	return rl
+}
+
+// rateLimit throttles each client IP per route, using the budget of the
+// first configured rule the request matches and the default budget for
+// everything else. Every response carries X-RateLimit-* headers; requests
+// over budget get a 429 with Retry-After.
+func (app *application) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.config.rateLimit.enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		route := "default"
+		budget := app.config.rateLimit.defaults
+		for _, rule := range app.config.rateLimit.routes {
+			if rule.match(r) {
+				route = rule.name()
+				budget = rule.rateLimit
+				break
+			}
+		}
+
+		key := fmt.Sprintf("ratelimit:%s:%s", route, app.clientIP(r))
+		now := time.Now()
+
+		result, err := app.allowRequest(r.Context(), key, budget, now)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if result.remaining < 0 {
+			result.remaining = 0
+		}
+
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(budget.limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(result.reset.Unix(), 10))
+
+		if !result.allowed {
+			retryAfter := int(math.Ceil(result.reset.Sub(now).Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			app.rateLimitExceededResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowRequest counts the request in redis, switching to the in-memory
+// limiter (and logging the switch once) while redis is failing.
+func (app *application) allowRequest(ctx context.Context, key string, budget rateLimit, now time.Time) (limitResult, error) {
+	l := app.limiter
+
+	if l.primary != nil && l.tryPrimary(now) {
+		result, err := l.primary.allow(ctx, key, budget, now)
+		if err == nil {
+			if atomic.CompareAndSwapInt32(&l.degraded, 1, 0) {
+				app.logger.Infow("redis rate limiter recovered", "tag", "rate-limit")
+			}
+			return result, nil
+		}
+
+		atomic.StoreInt64(&l.retryAt, now.Add(redisRetryInterval).UnixNano())
+		if atomic.CompareAndSwapInt32(&l.degraded, 0, 1) {
+			app.logger.Warnw("redis rate limiter unavailable, falling back to memory", "tag", "rate-limit", "error", err)
+		}
+	}
+
+	return l.fallback.allow(ctx, key, budget, now)
+}
+
+// tryPrimary reports whether a request at now should go to redis: always
+// while it is healthy, and once degraded only the first request after each
+// retry interval, which claims the probe for itself.
+func (l *rateLimiter) tryPrimary(now time.Time) bool {
+	if atomic.LoadInt32(&l.degraded) == 0 {
+		return true
+	}
+
+	retryAt := atomic.LoadInt64(&l.retryAt)
+	if now.UnixNano() < retryAt {
+		return false
+	}
+
+	return atomic.CompareAndSwapInt64(&l.retryAt, retryAt, now.Add(redisRetryInterval).UnixNano())
+}
@@ -0,0 +1,235 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    rateLimit
+		wantErr bool
+	}{
+		{value: "60/1m", want: rateLimit{limit: 60, window: time.Minute}},
+		{value: " 5/30s ", want: rateLimit{limit: 5, window: 30 * time.Second}},
+		{value: "60", wantErr: true},
+		{value: "0/1m", wantErr: true},
+		{value: "-1/1m", wantErr: true},
+		{value: "x/1m", wantErr: true},
+		{value: "60/0s", wantErr: true},
+		{value: "60/minute", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseRateLimit(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseRateLimit(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseRateLimit(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseRateLimitRules(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []rateLimitRule
+		wantErr bool
+	}{
+		{spec: ""},
+		{
+			spec: "post /v1/comments/:movie_name=10/1m, GET /v1/characters=30/1m,",
+			want: []rateLimitRule{
+				{method: "POST", pattern: "/v1/comments/:movie_name", rateLimit: rateLimit{limit: 10, window: time.Minute}},
+				{method: "GET", pattern: "/v1/characters", rateLimit: rateLimit{limit: 30, window: time.Minute}},
+			},
+		},
+		{spec: defaultRateLimitRoutes, want: mustParseRules(t, defaultRateLimitRoutes)},
+		{spec: "GET /v1/characters", wantErr: true},
+		{spec: "/v1/characters=30/1m", wantErr: true},
+		{spec: "GET v1/characters=30/1m", wantErr: true},
+		{spec: "GET /v1/characters=30", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseRateLimitRules(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseRateLimitRules(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseRateLimitRules(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func mustParseRules(t *testing.T, spec string) []rateLimitRule {
+	t.Helper()
+
+	rules, err := parseRateLimitRules(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rules
+}
+
+func TestRateLimitRuleMatch(t *testing.T) {
+	rule := rateLimitRule{method: http.MethodPost, pattern: "/v1/comments/:movie_name"}
+
+	tests := []struct {
+		method string
+		path   string
+		want   bool
+	}{
+		{method: http.MethodPost, path: "/v1/comments/a-new-hope", want: true},
+		{method: http.MethodPost, path: "/V1/Comments/4/", want: true},
+		{method: http.MethodGet, path: "/v1/comments/a-new-hope"},
+		{method: http.MethodPost, path: "/v1/comments/"},
+		{method: http.MethodPost, path: "/v1/comments//"},
+		{method: http.MethodPost, path: "/v1/comments/a-new-hope/1"},
+		{method: http.MethodPost, path: "/v1/movies/4"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		if got := rule.match(r); got != tt.want {
+			t.Errorf("match(%s %s) = %v, want %v", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	budget := rateLimit{limit: 2, window: time.Minute}
+	start := time.Unix(1_700_000_000, 0)
+
+	tests := []struct {
+		key       string
+		at        time.Duration
+		allowed   bool
+		remaining int
+		reset     time.Duration
+	}{
+		{key: "a", at: 0, allowed: true, remaining: 1, reset: time.Minute},
+		{key: "a", at: 10 * time.Second, allowed: true, remaining: 0, reset: time.Minute},
+		{key: "a", at: 20 * time.Second, allowed: false, remaining: 0, reset: time.Minute},
+		{key: "b", at: 20 * time.Second, allowed: true, remaining: 1, reset: 80 * time.Second},
+		// the first hit has slid out of the window, the second hasn't
+		{key: "a", at: 61 * time.Second, allowed: true, remaining: 0, reset: 70 * time.Second},
+		{key: "a", at: 200 * time.Second, allowed: true, remaining: 1, reset: 260 * time.Second},
+	}
+
+	l := newMemoryLimiter()
+	for i, tt := range tests {
+		got, err := l.allow(context.Background(), tt.key, budget, start.Add(tt.at))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := limitResult{allowed: tt.allowed, remaining: tt.remaining, reset: start.Add(tt.reset)}
+		if got != want {
+			t.Errorf("request %d (%s at %s) = %+v, want %+v", i, tt.key, tt.at, got, want)
+		}
+	}
+
+	// b has been quiet for longer than the window and is forgotten
+	if _, ok := l.hits["b"]; ok {
+		t.Error("quiet client b was not swept")
+	}
+}
+
+// failingLimiter is a primary limiter that is always down.
+type failingLimiter struct {
+	calls int32
+}
+
+func (l *failingLimiter) allow(ctx context.Context, key string, rl rateLimit, now time.Time) (limitResult, error) {
+	atomic.AddInt32(&l.calls, 1)
+	return limitResult{}, errors.New("redis: connection refused")
+}
+
+func TestAllowRequestBacksOff(t *testing.T) {
+	app := newTestApplication(t)
+	primary := &failingLimiter{}
+	app.limiter.primary = primary
+
+	budget := rateLimit{limit: 100, window: time.Minute}
+	start := time.Now()
+
+	tests := []struct {
+		at    time.Duration
+		calls int32
+	}{
+		{at: 0, calls: 1},
+		{at: time.Second, calls: 1},
+		{at: redisRetryInterval - time.Millisecond, calls: 1},
+		{at: redisRetryInterval, calls: 2},
+		{at: redisRetryInterval + time.Second, calls: 2},
+		{at: 2 * redisRetryInterval, calls: 3},
+	}
+
+	for _, tt := range tests {
+		result, err := app.allowRequest(context.Background(), "key", budget, start.Add(tt.at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.allowed {
+			t.Errorf("request at %s was rejected by the fallback", tt.at)
+		}
+		if calls := atomic.LoadInt32(&primary.calls); calls != tt.calls {
+			t.Errorf("after a request at %s redis was tried %d times, want %d", tt.at, calls, tt.calls)
+		}
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.rateLimit.enabled = true
+	app.config.rateLimit.defaults = rateLimit{limit: 2, window: time.Minute}
+	app.config.rateLimit.routes = mustParseRules(t, "POST /v1/comments/:movie_name=1/1m")
+
+	handler := app.rateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		method     string
+		path       string
+		remoteAddr string
+		status     int
+		remaining  string
+	}{
+		{method: http.MethodGet, path: "/v1/movies", remoteAddr: "203.0.113.1:1", status: http.StatusNoContent, remaining: "1"},
+		{method: http.MethodGet, path: "/v1/characters", remoteAddr: "203.0.113.1:2", status: http.StatusNoContent, remaining: "0"},
+		{method: http.MethodGet, path: "/v1/movies", remoteAddr: "203.0.113.1:3", status: http.StatusTooManyRequests, remaining: "0"},
+		{method: http.MethodGet, path: "/v1/movies", remoteAddr: "203.0.113.2:1", status: http.StatusNoContent, remaining: "1"},
+		{method: http.MethodPost, path: "/v1/comments/4", remoteAddr: "203.0.113.1:4", status: http.StatusNoContent, remaining: "0"},
+		{method: http.MethodPost, path: "/v1/comments/5", remoteAddr: "203.0.113.1:5", status: http.StatusTooManyRequests, remaining: "0"},
+	}
+
+	for i, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		r.RemoteAddr = tt.remoteAddr
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if rr.Code != tt.status {
+			t.Errorf("request %d: status %d, want %d", i, rr.Code, tt.status)
+		}
+		if got := rr.Header().Get("X-RateLimit-Remaining"); got != tt.remaining {
+			t.Errorf("request %d: X-RateLimit-Remaining %q, want %q", i, got, tt.remaining)
+		}
+		if retryAfter := rr.Header().Get("Retry-After"); (retryAfter != "") != (tt.status == http.StatusTooManyRequests) {
+			t.Errorf("request %d: Retry-After %q with status %d", i, retryAfter, rr.Code)
+		}
+	}
+}
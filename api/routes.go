@@ -3,6 +3,7 @@ package api
 import (
 	"net/http"
 
+	"github.com/JacobNewton007/busha-test/internals/data"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -15,7 +16,22 @@ func (app *application) routes() http.Handler {
 
 	router.HandlerFunc(http.MethodGet, "/v1/comments/:movie_name", app.MovieCommentsHandler)
 	router.HandlerFunc(http.MethodPost, "/V1/comments/:movie_name", app.CreateCommentHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/comments/:movie_name/:id", app.ShowCommentHandler)
+	router.HandlerFunc(http.MethodPatch, "/v1/comments/:movie_name/:id", app.UpdateCommentHandler)
+	router.HandlerFunc(http.MethodDelete, "/v1/comments/:movie_name/:id", app.DeleteCommentHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/comments/:movie_name/:id/reactions", app.CreateReactionHandler)
+	router.HandlerFunc(http.MethodDelete, "/v1/comments/:movie_name/:id/reactions", app.DeleteReactionHandler)
+
+	router.HandlerFunc(http.MethodGet, "/v1/admin/comments", app.requireAdmin(app.ModerationQueueHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/comments/:id/hide", app.requireAdmin(app.moderateCommentHandler([]string{data.StatusVisible, data.StatusPending}, data.StatusHidden, "comment hidden")))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/comments/:id/restore", app.requireAdmin(app.moderateCommentHandler([]string{data.StatusHidden, data.StatusDeleted}, data.StatusVisible, "comment restored")))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/comments/:id/approve", app.requireAdmin(app.moderateCommentHandler([]string{data.StatusPending}, data.StatusVisible, "comment approved")))
+
+	router.HandlerFunc(http.MethodGet, "/v1/internal/cache", app.requireAdmin(app.CacheStatusHandler))
+
 	router.HandlerFunc(http.MethodGet, "/v1/movies", app.GetMovieHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.ShowMovieHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/characters", app.MovieCharactersHandler)
 	router.HandlerFunc(http.MethodGet, "/v1/characters", app.GetCharactersHandler)
 
 	return router
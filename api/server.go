@@ -1,9 +1,13 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	// "go.uber.org/zap"
@@ -15,12 +19,29 @@ func (app *application) server() error {
 
 	srv := &http.Server{
 		Addr: fmt.Sprintf(":%d", app.config.port),
-		Handler: app.routes(),
+		Handler: app.rateLimit(app.routes()),
 		IdleTimeout:  time.Minute,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
 	}
 
+	// on SIGINT or SIGTERM, stop accepting connections and give in-flight
+	// requests a grace period to finish
+	shutdownError := make(chan error)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+
+		app.logger.Infow("shutting down server", "signal", s.String())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+
+		shutdownError <- srv.Shutdown(ctx)
+	}()
+
 	app.logger.Infow("starting server", "addr", srv.Addr, "env", app.config.env)
 
 	err := srv.ListenAndServe()
@@ -28,5 +49,12 @@ func (app *application) server() error {
 		return err
 	}
 
+	err = <-shutdownError
+	if err != nil {
+		return err
+	}
+
+	app.logger.Infow("stopped server", "addr", srv.Addr)
+
 	return nil
 }
\ No newline at end of file
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/JacobNewton007/busha-test/internals/swapi"
+	"github.com/joho/godotenv"
+)
+
+// swapiCachePrefixes are the cache keys holding data derived from SWAPI,
+// dropped whenever the snapshot behind it changes.
+var swapiCachePrefixes = []string{"swapi:", "movies", "characters", "films:"}
+
+// runSnapshotWatch reloads the SWAPI snapshot whenever its files change,
+// polling every interval until ctx is cancelled. It does nothing unless
+// the api was started with --swapi-snapshot.
+func (app *application) runSnapshotWatch(ctx context.Context, interval time.Duration) {
+	snapshot, ok := app.swapi.(*swapi.Snapshot)
+	if !ok || interval <= 0 {
+		return
+	}
+
+	app.logger.Infow("watching SWAPI snapshot", "tag", "swapi-snapshot", "dir", app.config.swapi.snapshot, "interval", interval.String())
+
+	snapshot.Watch(ctx, interval, func(err error) {
+		if err != nil {
+			app.logger.Errorw("SWAPI snapshot reload failed, still serving the previous one", "tag", "swapi-snapshot", "error", err)
+			return
+		}
+
+		for _, prefix := range swapiCachePrefixes {
+			err := app.cache.DeletePrefix(ctx, prefix)
+			if err != nil {
+				app.logger.Errorw("clearing cache after SWAPI snapshot reload failed", "tag", "swapi-snapshot", "prefix", prefix, "error", err)
+			}
+		}
+
+		app.logger.Infow("SWAPI snapshot reloaded", "tag", "swapi-snapshot", "dir", app.config.swapi.snapshot)
+	})
+
+	app.logger.Infow("stopped watching SWAPI snapshot", "tag", "swapi-snapshot")
+}
+
+// RunSnapshot runs the snapshot command, whose only subcommand, export,
+// captures the live SWAPI into a directory --swapi-snapshot can serve. It
+// returns the process exit code.
+func RunSnapshot(args []string) int {
+	if len(args) == 0 || args[0] != "export" {
+		fmt.Fprintln(os.Stderr, "usage: snapshot export --out DIR [--format json|ndjson] [--url URL] [--timeout DURATION]")
+		return 2
+	}
+
+	// SWAPI_URL may come from the same .env the api reads
+	_ = godotenv.Load(".env")
+
+	flags := flag.NewFlagSet("snapshot export", flag.ContinueOnError)
+	out := flags.String("out", "", "Directory to write the snapshot to (required)")
+	format := flags.String("format", swapi.FormatJSON, "Snapshot format, json or ndjson")
+	url := flags.String("url", getenv("SWAPI_URL", swapi.DefaultBaseURL), "SWAPI base url to export from")
+	timeout := flags.Duration("timeout", 30*time.Second, "Timeout for each SWAPI request")
+
+	err := flags.Parse(args[1:])
+	if err != nil {
+		return 2
+	}
+
+	// no default, so an export never overwrites the recorded fixtures in
+	// testdata/swapi by accident
+	if *out == "" {
+		fmt.Fprintln(os.Stderr, "snapshot export: --out is required")
+		flags.Usage()
+		return 2
+	}
+
+	start := time.Now()
+
+	counts, err := swapi.Export(context.Background(), swapi.New(*url, *timeout), *out, *format)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	for _, resource := range swapi.Resources {
+		fmt.Printf("%-10s %d\n", resource, counts[resource])
+	}
+	fmt.Printf("exported %s to %s in %s\n", *url, *out, time.Since(start).Round(time.Millisecond))
+
+	return 0
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/JacobNewton007/busha-test/internals/cache"
+	custom_validator "github.com/JacobNewton007/busha-test/internals/custom_validator"
+	"github.com/JacobNewton007/busha-test/internals/data"
+	"github.com/JacobNewton007/busha-test/internals/swapi"
+	"go.uber.org/zap"
+)
+
+// newTestApplication returns an application serving the recorded SWAPI in
+// testdata, cached in memory, with no database behind it.
+func newTestApplication(t *testing.T) *application {
+	t.Helper()
+
+	store := cache.NewLRU(1024)
+
+	return &application{
+		logger:    *zap.NewNop().Sugar(),
+		cache:     store,
+		loader:    cache.NewLoader(store, time.Hour),
+		swapi:     swapi.NewFake("../testdata/swapi"),
+		validator: custom_validator.New(),
+		limiter:   newRateLimiter(nil),
+		warmer:    newCacheWarmer(0),
+	}
+}
+
+// mockModels points app's models at a sqlmock database, which fails the test
+// when an expected query was not run.
+func mockModels(t *testing.T, app *application) sqlmock.Sqlmock {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+
+	app.models = data.CommentFactory(db)
+	return mock
+}
+
+// commentRows returns comments as sqlmock rows, in the column order the
+// comment queries select.
+func commentRows(comments ...*data.Comment) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "created_at", "comment", "movie_name", "episode_id", "commenter_ip", "version", "status", "deleted_at", "moderated_by", "parent_id"})
+
+	for _, c := range comments {
+		var episodeID, deletedAt, moderatedBy, parentID driver.Value
+		if c.EpisodeID != nil {
+			episodeID = int64(*c.EpisodeID)
+		}
+		if c.DeletedAt != nil {
+			deletedAt = *c.DeletedAt
+		}
+		if c.ModeratedBy != nil {
+			moderatedBy = *c.ModeratedBy
+		}
+		if c.ParentID != nil {
+			parentID = *c.ParentID
+		}
+
+		rows.AddRow(c.ID, c.CreatedAt, c.Comment, c.Movie, episodeID, c.CommenterIp, c.Version, c.Status, deletedAt, moderatedBy, parentID)
+	}
+
+	return rows
+}
+
+// reactionRows returns rows of the reaction counts query.
+func reactionRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"comment_id", "reaction", "count"})
+}
+
+// get sends a GET for target through handler and decodes the JSON response
+// into an envelope.
+func get(t *testing.T, handler http.Handler, target string) (int, map[string]json.RawMessage) {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+
+	var body map[string]json.RawMessage
+	err := json.Unmarshal(rr.Body.Bytes(), &body)
+	if err != nil {
+		t.Fatalf("GET %s: decoding %q: %v", target, rr.Body.String(), err)
+	}
+
+	return rr.Code, body
+}
+
+func mustParseQuery(t *testing.T, query string) url.Values {
+	t.Helper()
+
+	qs, err := url.ParseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return qs
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// warmerStatus is what GET /v1/internal/cache reports about the cache
+// warmer. Errors holds the failures of the last run by cache key.
+type warmerStatus struct {
+	Enabled      bool              `json:"enabled"`
+	Interval     string            `json:"interval"`
+	Running      bool              `json:"running"`
+	Runs         int               `json:"runs"`
+	LastRun      *time.Time        `json:"last_run"`
+	LastSuccess  *time.Time        `json:"last_success"`
+	LastDuration string            `json:"last_duration,omitempty"`
+	Errors       map[string]string `json:"errors"`
+}
+
+// cacheWarmer records the outcome of each warming run for the status
+// endpoint.
+type cacheWarmer struct {
+	mu     sync.Mutex
+	status warmerStatus
+}
+
+func newCacheWarmer(interval time.Duration) *cacheWarmer {
+	return &cacheWarmer{status: warmerStatus{
+		Enabled:  interval > 0,
+		Interval: interval.String(),
+		Errors:   map[string]string{},
+	}}
+}
+
+func (cw *cacheWarmer) snapshot() warmerStatus {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	status := cw.status
+	status.Errors = make(map[string]string, len(cw.status.Errors))
+	for key, err := range cw.status.Errors {
+		status.Errors[key] = err
+	}
+	return status
+}
+
+// runWarmer warms the cache straight away and then every interval until
+// ctx is cancelled, so the first request after a deploy or an expiry
+// doesn't pay for the SWAPI round trips.
+func (app *application) runWarmer(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	app.logger.Infow("starting cache warmer", "tag", "cache-warmer", "interval", interval.String())
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		app.warmCache(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			app.logger.Infow("stopped cache warmer", "tag", "cache-warmer")
+			return
+		}
+	}
+}
+
+// warmKey is a cache key the warmer refreshes and the load that fills it.
+type warmKey struct {
+	key   string
+	fetch func(ctx context.Context) (interface{}, error)
+}
+
+// warmCache refreshes the film list and every people page under the keys
+// the handlers read.
+func (app *application) warmCache(ctx context.Context) {
+	warmer := app.warmer
+	start := time.Now()
+
+	warmer.mu.Lock()
+	warmer.status.Running = true
+	warmer.mu.Unlock()
+
+	keys := []warmKey{
+		{"movies", func(ctx context.Context) (interface{}, error) { return app.loadMovies(ctx) }},
+	}
+	// with the Postgres catalogue, films and characters never come from
+	// these keys
+	if !app.fromPostgres() {
+		keys = append(keys,
+			warmKey{"swapi:films", func(ctx context.Context) (interface{}, error) { return app.swapi.Films(ctx) }},
+			warmKey{"characters", func(ctx context.Context) (interface{}, error) { return app.loadCharacters(ctx) }},
+		)
+	}
+
+	errs := map[string]string{}
+	for _, k := range keys {
+		err := app.loader.Refresh(ctx, k.key, k.fetch)
+		if err != nil {
+			errs[k.key] = err.Error()
+		}
+	}
+
+	duration := time.Since(start)
+
+	warmer.mu.Lock()
+	warmer.status.Running = false
+	warmer.status.Runs++
+	warmer.status.LastRun = &start
+	warmer.status.LastDuration = duration.String()
+	warmer.status.Errors = errs
+	if len(errs) == 0 {
+		warmer.status.LastSuccess = &start
+	}
+	warmer.mu.Unlock()
+
+	if len(errs) > 0 {
+		app.logger.Errorw("cache warming failed", "tag", "cache-warmer", "duration", duration.String(), "errors", errs)
+		return
+	}
+
+	app.logger.Infow("cache warmed", "tag", "cache-warmer", "duration", duration.String(), "keys", len(keys))
+}
+
+func (app *application) CacheStatusHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, http.StatusOK, envelope{"warmer": app.warmer.snapshot(), "status": "success"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
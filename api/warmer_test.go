@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestWarmCache(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.admin.token = "secret"
+	app.warmer = newCacheWarmer(30 * time.Minute)
+	mock := mockModels(t, app)
+
+	// the movie list is loaded once, by the warmer
+	mock.ExpectQuery(regexp.QuoteMeta("FROM unnest($1::bigint[], $2::text[])")).
+		WillReturnRows(sqlmock.NewRows([]string{"episode_id", "count"}))
+
+	app.warmCache(context.Background())
+
+	routes := app.routes()
+
+	status, _ := get(t, routes, "/v1/movies")
+	if status != http.StatusOK {
+		t.Fatalf("GET /v1/movies: status %d, want %d", status, http.StatusOK)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/internal/cache", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+
+	routes.ServeHTTP(rr, r)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET /v1/internal/cache: status %d, want %d", rr.Code, http.StatusOK)
+	}
+
+	var body struct {
+		Warmer warmerStatus `json:"warmer"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	got := body.Warmer
+	if !got.Enabled || got.Interval != "30m0s" || got.Runs != 1 || got.Running {
+		t.Errorf("warmer = %+v, want one finished run every 30m", got)
+	}
+	if got.LastSuccess == nil || len(got.Errors) != 0 {
+		t.Errorf("warmer = %+v, want a successful run", got)
+	}
+}
+
+func TestCacheStatusHandlerRequiresAdmin(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.admin.token = "secret"
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/internal/cache", nil)
+	r.Header.Set("Authorization", "Bearer wrong")
+	rr := httptest.NewRecorder()
+
+	app.routes().ServeHTTP(rr, r)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("status %d, want %d", rr.Code, http.StatusUnauthorized)
+	}
+}
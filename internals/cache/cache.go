@@ -0,0 +1,60 @@
+// Package cache stores short-lived values by key, either in redis or in an
+// in-process LRU, behind one interface.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrMiss is returned by Get when key is not cached or has expired.
+	ErrMiss = errors.New("cache: miss")
+)
+
+// Cache is a key/value store whose entries expire. A ttl of zero means the
+// entry never expires; Expire with a ttl of zero or less removes the entry.
+// Deleting or expiring a key that isn't cached is not an error.
+// DeletePrefix removes every key starting with prefix.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	DeletePrefix(ctx context.Context, prefix string) error
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+}
+
+// GetJSON decodes the value cached under key into dst.
+func GetJSON(ctx context.Context, c Cache, key string, dst interface{}) error {
+	value, err := c.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(value, dst)
+	if err != nil {
+		return fmt.Errorf("cache: decoding %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// SetJSON caches the JSON encoding of value under key for ttl.
+func SetJSON(ctx context.Context, c Cache, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("cache: encoding %s: %w", key, err)
+	}
+
+	return c.Set(ctx, key, data, ttl)
+}
+
+// Locker takes short-lived locks shared between replicas of the api. Lock
+// reports ok=false, without an error, when someone else holds key; unlock
+// releases the lock only if it is still ours.
+type Locker interface {
+	Lock(ctx context.Context, key string, ttl time.Duration) (unlock func(), ok bool, err error)
+}
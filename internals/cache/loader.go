@@ -0,0 +1,237 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// entry is how a Loader stores a value: the value itself and the time
+// after which it is stale and due for a refresh.
+type entry struct {
+	Value      json.RawMessage `json:"value"`
+	FreshUntil time.Time       `json:"fresh_until"`
+}
+
+// Loader is a cache-aside layer for slow loads such as SWAPI fetches. It
+// protects the source from stampedes when popular keys expire:
+//
+//   - concurrent misses for the same key share a single load;
+//   - a stale value keeps being served for up to Stale past its TTL while
+//     one background refresh replaces it;
+//   - TTLs are jittered so keys cached together don't expire together;
+//   - with a Lock, only one replica of the api loads a key at a time and
+//     the others wait for its result.
+type Loader struct {
+	Cache Cache
+	// TTL is how long a loaded value is fresh, give or take Jitter.
+	TTL time.Duration
+	// Stale is how long a value may be served past its TTL while it is
+	// being refreshed in the background.
+	Stale time.Duration
+	// Jitter is the fraction of TTL by which each TTL randomly varies.
+	Jitter float64
+	// Lock, when set, is taken around every load so replicas sharing the
+	// cache don't refresh the same key at once.
+	Lock Locker
+	// LockTTL bounds how long a load may hold the lock, and how long other
+	// replicas wait for it.
+	LockTTL time.Duration
+	// Timeout bounds a load. Loads run detached from the request that
+	// triggered them, since other requests may be waiting on the result.
+	Timeout time.Duration
+	// OnError reports failures that no caller sees: background refreshes
+	// and writes back to the cache.
+	OnError func(key string, err error)
+
+	group group
+	mu    sync.Mutex
+	rand  *rand.Rand
+}
+
+// NewLoader returns a Loader over c whose values are fresh for about ttl
+// and may be served stale for a further quarter of ttl.
+func NewLoader(c Cache, ttl time.Duration) *Loader {
+	return &Loader{
+		Cache:   c,
+		TTL:     ttl,
+		Stale:   ttl / 4,
+		Jitter:  0.1,
+		LockTTL: 30 * time.Second,
+		Timeout: 30 * time.Second,
+	}
+}
+
+// Load decodes the value cached under key into dst. On a miss it calls
+// fetch, caches what it returns and decodes that into dst. A stale value is
+// returned immediately while fetch refreshes it in the background.
+func (l *Loader) Load(ctx context.Context, key string, dst interface{}, fetch func(ctx context.Context) (interface{}, error)) error {
+	var e entry
+	err := GetJSON(ctx, l.Cache, key, &e)
+	if err == nil && e.Value != nil {
+		if time.Now().After(e.FreshUntil) {
+			l.group.do(key, func() ([]byte, error) {
+				value, err := l.load(key, fetch, false)
+				if err != nil {
+					l.report(key, err)
+				}
+				return value, err
+			})
+		}
+		return json.Unmarshal(e.Value, dst)
+	}
+
+	c := l.group.do(key, func() ([]byte, error) {
+		return l.load(key, fetch, true)
+	})
+
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	value, err := c.val, c.err
+	if errors.Is(err, errLocked) {
+		// we joined a refresh that another replica beat us to, after the
+		// stale value it was refreshing expired
+		var ok bool
+		value, ok = l.await(ctx, key)
+		if !ok {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(value, dst)
+}
+
+// Refresh loads key with fetch and caches it whether or not the cached
+// value is still fresh, so a warmer can replace values before they expire.
+// It joins a load of key that is already running, and does nothing when
+// another replica holds the key's lock.
+func (l *Loader) Refresh(ctx context.Context, key string, fetch func(ctx context.Context) (interface{}, error)) error {
+	c := l.group.do(key, func() ([]byte, error) {
+		return l.load(key, fetch, false)
+	})
+
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if errors.Is(c.err, errLocked) {
+		return nil
+	}
+	return c.err
+}
+
+// errLocked is returned by a refresh that found another replica already
+// refreshing the key.
+var errLocked = errors.New("cache: key is being loaded elsewhere")
+
+// load fetches and caches key. When another replica holds the key's lock,
+// a miss waits for that replica's value and a refresh gives up, leaving the
+// stale value in place.
+func (l *Loader) load(key string, fetch func(ctx context.Context) (interface{}, error), wait bool) ([]byte, error) {
+	ctx := context.Background()
+	if l.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.Timeout)
+		defer cancel()
+	}
+
+	if l.Lock != nil {
+		unlock, ok, err := l.Lock.Lock(ctx, "lock:"+key, l.LockTTL)
+		switch {
+		case err != nil:
+			// without a working lock, loading anyway beats failing
+			l.report(key, err)
+		case ok:
+			defer unlock()
+		case !wait:
+			return nil, errLocked
+		default:
+			if value, ok := l.await(ctx, key); ok {
+				return value, nil
+			}
+		}
+	}
+
+	value, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := l.jitter(l.TTL)
+	err = SetJSON(ctx, l.Cache, key, entry{Value: data, FreshUntil: time.Now().Add(ttl)}, ttl+l.Stale)
+	if err != nil {
+		// the value is still good to return, it just won't be cached
+		l.report(key, err)
+	}
+
+	return data, nil
+}
+
+// awaitInterval is how often a replica waiting on another one's load
+// checks the cache for the result.
+const awaitInterval = 100 * time.Millisecond
+
+// await polls the cache for a fresh value of key while another replica
+// loads it, for at most LockTTL.
+func (l *Loader) await(ctx context.Context, key string) ([]byte, bool) {
+	deadline := time.Now().Add(l.LockTTL)
+	ticker := time.NewTicker(awaitInterval)
+	defer ticker.Stop()
+
+	for time.Now().Before(deadline) {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, false
+		}
+
+		var e entry
+		err := GetJSON(ctx, l.Cache, key, &e)
+		if err == nil && e.Value != nil && time.Now().Before(e.FreshUntil) {
+			return e.Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// jitter spreads ttl by up to Jitter of itself in either direction.
+func (l *Loader) jitter(ttl time.Duration) time.Duration {
+	if l.Jitter <= 0 || ttl <= 0 {
+		return ttl
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// each replica seeds its own source, otherwise they would all draw the
+	// same "random" TTLs
+	if l.rand == nil {
+		l.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	spread := (l.rand.Float64()*2 - 1) * l.Jitter
+	return ttl + time.Duration(float64(ttl)*spread)
+}
+
+func (l *Loader) report(key string, err error) {
+	if l.OnError != nil && !errors.Is(err, errLocked) {
+		l.OnError(key, err)
+	}
+}
@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// counter is a fetch that returns how many times it has been called,
+// after waiting for delay.
+type counter struct {
+	calls int32
+	delay time.Duration
+	err   error
+}
+
+func (c *counter) fetch(ctx context.Context) (interface{}, error) {
+	n := atomic.AddInt32(&c.calls, 1)
+	time.Sleep(c.delay)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return n, nil
+}
+
+func (c *counter) count() int32 {
+	return atomic.LoadInt32(&c.calls)
+}
+
+// busyLocker is a Locker that someone else always holds.
+type busyLocker struct{}
+
+func (busyLocker) Lock(ctx context.Context, key string, ttl time.Duration) (func(), bool, error) {
+	return nil, false, nil
+}
+
+func TestLoaderMiss(t *testing.T) {
+	errFetch := errors.New("swapi is down")
+
+	tests := []struct {
+		name    string
+		callers int
+		err     error
+		want    int32
+	}{
+		{name: "one caller", callers: 1, want: 1},
+		{name: "concurrent callers share one load", callers: 50, want: 1},
+		{name: "errors reach every caller", callers: 10, err: errFetch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLoader(NewLRU(16), time.Minute)
+			f := &counter{delay: 50 * time.Millisecond, err: tt.err}
+
+			var wg sync.WaitGroup
+			for i := 0; i < tt.callers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					var got int32
+					err := l.Load(context.Background(), "key", &got, f.fetch)
+					if !errors.Is(err, tt.err) {
+						t.Errorf("Load error = %v, want %v", err, tt.err)
+					}
+					if got != tt.want {
+						t.Errorf("Load = %d, want %d", got, tt.want)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if f.count() != 1 {
+				t.Errorf("fetched %d times, want 1", f.count())
+			}
+		})
+	}
+}
+
+func TestLoaderErrorsAreNotCached(t *testing.T) {
+	l := NewLoader(NewLRU(16), time.Minute)
+	f := &counter{err: errors.New("swapi is down")}
+
+	var got int32
+	_ = l.Load(context.Background(), "key", &got, f.fetch)
+
+	f.err = nil
+	err := l.Load(context.Background(), "key", &got, f.fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 2 || f.count() != 2 {
+		t.Errorf("Load = %d after %d fetches, want a fresh load after the failure", got, f.count())
+	}
+}
+
+func TestLoaderServesStale(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(16)
+	l := NewLoader(c, time.Minute)
+
+	err := SetJSON(ctx, c, "key", entry{Value: []byte("0"), FreshUntil: time.Now().Add(-time.Second)}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &counter{delay: 50 * time.Millisecond}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var got int32
+			err := l.Load(ctx, "key", &got, f.fetch)
+			if err != nil || got != 0 {
+				t.Errorf("Load = %d, %v, want the stale 0", got, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		var got int32
+		err := l.Load(ctx, "key", &got, f.fetch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("stale value never refreshed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if f.count() != 1 {
+		t.Errorf("refreshed %d times, want 1", f.count())
+	}
+}
+
+func TestLoaderRefresh(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		lock Locker
+		want int32
+	}{
+		{name: "replaces a fresh value", want: 2},
+		{name: "skipped while another replica loads", lock: busyLocker{}, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLoader(NewLRU(16), time.Minute)
+			f := &counter{}
+
+			var got int32
+			err := l.Load(ctx, "key", &got, f.fetch)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			l.Lock = tt.lock
+			err = l.Refresh(ctx, "key", f.fetch)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = l.Load(ctx, "key", &got, f.fetch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Load after Refresh = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoaderJitter(t *testing.T) {
+	l := NewLoader(NewLRU(1), time.Minute)
+
+	for i := 0; i < 100; i++ {
+		ttl := l.jitter(time.Minute)
+		if ttl < 54*time.Second || ttl > 66*time.Second {
+			t.Fatalf("jitter(1m) = %s, want within 10%%", ttl)
+		}
+	}
+
+	l.Jitter = 0
+	if ttl := l.jitter(time.Minute); ttl != time.Minute {
+		t.Errorf("jitter(1m) without Jitter = %s", ttl)
+	}
+}
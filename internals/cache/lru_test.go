@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+
+	type step struct {
+		op    string
+		key   string
+		value string
+		ttl   time.Duration
+		// want is the value Get should return, or "" for a miss.
+		want string
+	}
+
+	tests := []struct {
+		name     string
+		capacity int
+		steps    []step
+	}{
+		{
+			name:     "get what was set",
+			capacity: 2,
+			steps: []step{
+				{op: "set", key: "a", value: "1"},
+				{op: "get", key: "a", want: "1"},
+				{op: "get", key: "b"},
+			},
+		},
+		{
+			name:     "evicts the least recently used",
+			capacity: 2,
+			steps: []step{
+				{op: "set", key: "a", value: "1"},
+				{op: "set", key: "b", value: "2"},
+				{op: "get", key: "a", want: "1"},
+				{op: "set", key: "c", value: "3"},
+				{op: "get", key: "b"},
+				{op: "get", key: "a", want: "1"},
+				{op: "get", key: "c", want: "3"},
+			},
+		},
+		{
+			name:     "overwrites in place",
+			capacity: 2,
+			steps: []step{
+				{op: "set", key: "a", value: "1"},
+				{op: "set", key: "b", value: "2"},
+				{op: "set", key: "a", value: "3"},
+				{op: "set", key: "c", value: "4"},
+				{op: "get", key: "a", want: "3"},
+				{op: "get", key: "b"},
+			},
+		},
+		{
+			name:     "expired entries miss",
+			capacity: 2,
+			steps: []step{
+				{op: "set", key: "a", value: "1", ttl: time.Nanosecond},
+				{op: "sleep", ttl: time.Millisecond},
+				{op: "get", key: "a"},
+			},
+		},
+		{
+			name:     "expire extends and removes",
+			capacity: 2,
+			steps: []step{
+				{op: "set", key: "a", value: "1", ttl: time.Nanosecond},
+				{op: "expire", key: "a", ttl: time.Hour},
+				{op: "sleep", ttl: time.Millisecond},
+				{op: "get", key: "a", want: "1"},
+				{op: "expire", key: "a"},
+				{op: "get", key: "a"},
+				{op: "expire", key: "missing", ttl: time.Hour},
+			},
+		},
+		{
+			name:     "delete",
+			capacity: 3,
+			steps: []step{
+				{op: "set", key: "a", value: "1"},
+				{op: "set", key: "b", value: "2"},
+				{op: "delete", key: "a"},
+				{op: "delete", key: "missing"},
+				{op: "get", key: "a"},
+				{op: "get", key: "b", want: "2"},
+			},
+		},
+		{
+			name:     "delete prefix",
+			capacity: 4,
+			steps: []step{
+				{op: "set", key: "swapi:films", value: "1"},
+				{op: "set", key: "swapi:people/1/", value: "2"},
+				{op: "set", key: "movies", value: "3"},
+				{op: "prefix", key: "swapi:"},
+				{op: "get", key: "swapi:films"},
+				{op: "get", key: "swapi:people/1/"},
+				{op: "get", key: "movies", want: "3"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewLRU(tt.capacity)
+
+			for i, s := range tt.steps {
+				var err error
+				switch s.op {
+				case "set":
+					err = c.Set(ctx, s.key, []byte(s.value), s.ttl)
+				case "expire":
+					err = c.Expire(ctx, s.key, s.ttl)
+				case "delete":
+					err = c.Delete(ctx, s.key)
+				case "prefix":
+					err = c.DeletePrefix(ctx, s.key)
+				case "sleep":
+					time.Sleep(s.ttl)
+				case "get":
+					var value []byte
+					value, err = c.Get(ctx, s.key)
+					if s.want == "" {
+						if !errors.Is(err, ErrMiss) {
+							t.Fatalf("step %d: Get(%q) = %q, %v, want a miss", i, s.key, value, err)
+						}
+						err = nil
+					} else if string(value) != s.want {
+						t.Fatalf("step %d: Get(%q) = %q, want %q", i, s.key, value, s.want)
+					}
+				}
+				if err != nil {
+					t.Fatalf("step %d: %s %q: %v", i, s.op, s.key, err)
+				}
+			}
+		})
+	}
+}
+
+func TestEscapeGlob(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "swapi:", want: "swapi:"},
+		{in: "characters?gender=*", want: `characters\?gender=\*`},
+		{in: `a[b]\c`, want: `a\[b\]\\c`},
+	}
+
+	for _, tt := range tests {
+		if got := escapeGlob(tt.in); got != tt.want {
+			t.Errorf("escapeGlob(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
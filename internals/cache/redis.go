@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type redisCache struct {
+	client *redis.Client
+}
+
+// NewRedis returns a Cache backed by client, shared by every instance of
+// the api that talks to the same redis.
+func NewRedis(client *redis.Client) Cache {
+	return &redisCache{client: client}
+}
+
+func (c *redisCache) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := c.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrMiss
+	}
+	return value, err
+}
+
+func (c *redisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (c *redisCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return c.client.Del(ctx, keys...).Err()
+}
+
+// DeletePrefix walks the matching keys with SCAN rather than KEYS, so a
+// large keyspace doesn't block redis.
+func (c *redisCache) DeletePrefix(ctx context.Context, prefix string) error {
+	iter := c.client.Scan(ctx, 0, escapeGlob(prefix)+"*", 100).Iterator()
+
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	return c.Delete(ctx, keys...)
+}
+
+// escapeGlob quotes the characters MATCH treats as a pattern.
+func escapeGlob(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`).Replace(s)
+}
+
+func (c *redisCache) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return c.client.Del(ctx, key).Err()
+	}
+	return c.client.Expire(ctx, key, ttl).Err()
+}
+
+type redisLocker struct {
+	client *redis.Client
+}
+
+// NewRedisLocker returns a Locker built on SET NX, so every replica using
+// the same redis sees the same locks.
+func NewRedisLocker(client *redis.Client) Locker {
+	return &redisLocker{client: client}
+}
+
+// unlockScript deletes the lock only if it still holds our token, so a
+// load that outlived its lock can't release someone else's.
+var unlockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+func (l *redisLocker) Lock(ctx context.Context, key string, ttl time.Duration) (func(), bool, error) {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return nil, false, err
+	}
+	token := hex.EncodeToString(id)
+
+	ok, err := l.client.SetNX(ctx, key, token, ttl).Result()
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	unlock := func() {
+		unlockScript.Run(context.Background(), l.client, []string{key}, token)
+	}
+
+	return unlock, true, nil
+}
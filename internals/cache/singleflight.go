@@ -0,0 +1,48 @@
+package cache
+
+import "sync"
+
+// call is a load in flight. done is closed once val and err are set.
+type call struct {
+	done chan struct{}
+	val  []byte
+	err  error
+}
+
+// group coalesces concurrent loads of the same key into one: whoever asks
+// for a key while its load is running waits for that load instead of
+// starting another.
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// do starts fn for key unless a load of key is already running, and
+// returns the running call either way. fn runs in its own goroutine so a
+// caller that gives up waiting doesn't cancel it for everyone else.
+func (g *group) do(key string, fn func() ([]byte, error)) *call {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	if c, ok := g.calls[key]; ok {
+		return c
+	}
+
+	c := &call{done: make(chan struct{})}
+	g.calls[key] = c
+
+	go func() {
+		c.val, c.err = fn()
+
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+
+		close(c.done)
+	}()
+
+	return c
+}
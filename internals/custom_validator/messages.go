@@ -0,0 +1,67 @@
+package validator
+
+// Keys of the messages the api localizes through the same translators as
+// the validation errors. Placeholders such as {0} are filled in by Message.
+const (
+	MsgNotFound         = "not_found"
+	MsgMethodNotAllowed = "method_not_allowed"
+	MsgServerError      = "server_error"
+	MsgEditConflict     = "edit_conflict"
+	MsgInvalidToken     = "invalid_token"
+	MsgParentNotFound   = "parent_not_found"
+	MsgOneOf            = "one_of"
+	MsgRateLimited      = "rate_limited"
+	MsgVersionRequired  = "version_required"
+	MsgNotPermitted     = "not_permitted"
+)
+
+var catalog = map[string]map[string]string{
+	"en": {
+		MsgNotFound:         "the requested resource could not be found",
+		MsgMethodNotAllowed: "the {0} method is not supported for this resource",
+		MsgServerError:      "the server encountered a problem and could not process your request",
+		MsgEditConflict:     "unable to update the record due to an edit conflict, please try again",
+		MsgInvalidToken:     "invalid or missing authentication token",
+		MsgParentNotFound:   "{0} must reference a comment on this movie",
+		MsgOneOf:            "{0} must be one of {1}",
+		MsgRateLimited:      "rate limit exceeded, please slow down and try again later",
+		MsgVersionRequired:  "the X-Expected-Version header is required to change this record",
+		MsgNotPermitted:     "only the client that posted this comment can change it",
+	},
+	"fr": {
+		MsgNotFound:         "la ressource demandée est introuvable",
+		MsgMethodNotAllowed: "la méthode {0} n'est pas prise en charge pour cette ressource",
+		MsgServerError:      "le serveur a rencontré un problème et n'a pas pu traiter votre requête",
+		MsgEditConflict:     "impossible de mettre à jour l'enregistrement à cause d'un conflit de modification, veuillez réessayer",
+		MsgInvalidToken:     "jeton d'authentification invalide ou manquant",
+		MsgParentNotFound:   "{0} doit faire référence à un commentaire de ce film",
+		MsgOneOf:            "{0} doit être l'une des valeurs suivantes : {1}",
+		MsgRateLimited:      "limite de requêtes dépassée, veuillez ralentir et réessayer plus tard",
+		MsgVersionRequired:  "l'en-tête X-Expected-Version est requis pour modifier cet enregistrement",
+		MsgNotPermitted:     "seul le client qui a publié ce commentaire peut le modifier",
+	},
+	"pt": {
+		MsgNotFound:         "o recurso solicitado não foi encontrado",
+		MsgMethodNotAllowed: "o método {0} não é suportado para este recurso",
+		MsgServerError:      "o servidor encontrou um problema e não pôde processar a sua solicitação",
+		MsgEditConflict:     "não foi possível atualizar o registro devido a um conflito de edição, tente novamente",
+		MsgInvalidToken:     "token de autenticação inválido ou ausente",
+		MsgParentNotFound:   "{0} deve referenciar um comentário deste filme",
+		MsgOneOf:            "{0} deve ser um dos seguintes valores: {1}",
+		MsgRateLimited:      "limite de requisições excedido, diminua o ritmo e tente novamente mais tarde",
+		MsgVersionRequired:  "o cabeçalho X-Expected-Version é obrigatório para alterar este registro",
+		MsgNotPermitted:     "somente o cliente que publicou este comentário pode alterá-lo",
+	},
+	"es": {
+		MsgNotFound:         "no se pudo encontrar el recurso solicitado",
+		MsgMethodNotAllowed: "el método {0} no está soportado para este recurso",
+		MsgServerError:      "el servidor encontró un problema y no pudo procesar su solicitud",
+		MsgEditConflict:     "no se pudo actualizar el registro por un conflicto de edición, inténtelo de nuevo",
+		MsgInvalidToken:     "token de autenticación inválido o ausente",
+		MsgParentNotFound:   "{0} debe hacer referencia a un comentario de esta película",
+		MsgOneOf:            "{0} debe ser uno de los siguientes valores: {1}",
+		MsgRateLimited:      "límite de solicitudes superado, reduzca el ritmo e inténtelo de nuevo más tarde",
+		MsgVersionRequired:  "se requiere la cabecera X-Expected-Version para modificar este registro",
+		MsgNotPermitted:     "solo el cliente que publicó este comentario puede modificarlo",
+	},
+}
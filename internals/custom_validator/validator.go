@@ -1,33 +1,168 @@
 package validator
 
 import (
-	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 
-	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/locales"
 	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/fr"
+	"github.com/go-playground/locales/pt"
+	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
 	en_translations "github.com/go-playground/validator/v10/translations/en"
-)	
+	es_translations "github.com/go-playground/validator/v10/translations/es"
+	fr_translations "github.com/go-playground/validator/v10/translations/fr"
+	pt_translations "github.com/go-playground/validator/v10/translations/pt"
+)
+
+// Validator owns a single go-playground validator together with a
+// translator per supported locale, so that failed checks come back as
+// readable messages, in the client's language, keyed by the field's JSON
+// name.
+type Validator struct {
+	validate *validator.Validate
+	uni      *ut.UniversalTranslator
+}
+
+type locale struct {
+	translator locales.Translator
+	register   func(*validator.Validate, ut.Translator) error
+}
+
+// supportedLocales lists every language the api answers in. English comes
+// first and is the fallback for anything else.
+var supportedLocales = []locale{
+	{en.New(), en_translations.RegisterDefaultTranslations},
+	{fr.New(), fr_translations.RegisterDefaultTranslations},
+	{pt.New(), pt_translations.RegisterDefaultTranslations},
+	{es.New(), es_translations.RegisterDefaultTranslations},
+}
 
-func Validator() ut.Translator{
+func New() *Validator {
 	validate := validator.New()
-	english := en.New()
-	uni := ut.New(english, english)
-	trans, _ := uni.GetTranslator("en")
-	_ = en_translations.RegisterDefaultTranslations(validate, trans)
+
+	// report fields by their json name, which is what clients sent us
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	uni := ut.New(supportedLocales[0].translator)
+
+	for _, l := range supportedLocales {
+		_ = uni.AddTranslator(l.translator, true)
+		trans, _ := uni.GetTranslator(l.translator.Locale())
+		_ = l.register(validate, trans)
+
+		for key, text := range catalog[l.translator.Locale()] {
+			_ = trans.Add(key, text, true)
+		}
+	}
+
+	return &Validator{validate: validate, uni: uni}
+}
+
+// Translator picks the best supported translator for an Accept-Language
+// header, falling back to English.
+func (v *Validator) Translator(acceptLanguage string) ut.Translator {
+	trans, _ := v.uni.FindTranslator(parseAcceptLanguage(acceptLanguage)...)
 	return trans
 }
 
+// Message returns the catalog message for key in the language picked from
+// acceptLanguage, with params substituted for its placeholders.
+func (v *Validator) Message(acceptLanguage string, key string, params ...string) string {
+	message, err := v.Translator(acceptLanguage).T(key, params...)
+	if err != nil {
+		message, _ = v.uni.GetFallback().T(key, params...)
+	}
+	return message
+}
+
+// parseAcceptLanguage returns the locales of an Accept-Language header in
+// order of preference. Regional tags are followed by their base language,
+// so "pt-BR" also matches "pt".
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "-", "_"))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if value := strings.TrimSpace(params); strings.HasPrefix(value, "q=") {
+			value = strings.TrimPrefix(value, "q=")
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+
+		tags = append(tags, weighted{tag, q})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	var result []string
+	for _, t := range tags {
+		result = append(result, t.tag)
+		if base, _, found := strings.Cut(t.tag, "_"); found {
+			result = append(result, base)
+		}
+	}
+
+	return result
+}
+
+// Errors maps a field name to the message describing why it is invalid.
+type Errors map[string]string
+
+// Check adds message for key when ok is false, keeping the first message
+// recorded for a key.
+func (e Errors) Check(ok bool, key, message string) {
+	if ok {
+		return
+	}
+	if _, exists := e[key]; !exists {
+		e[key] = message
+	}
+}
+
+// Struct validates s against its validate tags. It returns nil when s is
+// valid and otherwise one message per invalid field, in the language picked
+// from acceptLanguage.
+func (v *Validator) Struct(s interface{}, acceptLanguage string) Errors {
+	err := v.validate.Struct(s)
+	if err == nil {
+		return nil
+	}
+
+	errs := Errors{}
 
+	validationErrs, ok := err.(validator.ValidationErrors)
+	if !ok {
+		errs["_"] = err.Error()
+		return errs
+	}
 
-func TranslateError(err error, trans ut.Translator) (errs []error) {
-  if err == nil {
-    return nil
-  }
-  validatorErrs := err.(validator.ValidationErrors)
-  for _, e := range validatorErrs {
-    translatedErr := fmt.Errorf(e.Translate(trans))
-    errs = append(errs, translatedErr)
-  }
-  return errs
-}
\ No newline at end of file
+	trans := v.Translator(acceptLanguage)
+	for _, e := range validationErrs {
+		errs.Check(false, e.Field(), e.Translate(trans))
+	}
+
+	return errs
+}
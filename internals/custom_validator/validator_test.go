@@ -0,0 +1,115 @@
+package validator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{header: "", want: nil},
+		{header: "*", want: nil},
+		{header: "fr", want: []string{"fr"}},
+		{header: "pt-BR", want: []string{"pt_br", "pt"}},
+		{header: "en;q=0.5, es", want: []string{"es", "en"}},
+		{header: "de, fr-CA;q=0.8, en;q=0.9", want: []string{"de", "en", "fr_ca", "fr"}},
+		{header: "es;q=bad", want: []string{"es"}},
+	}
+
+	for _, tt := range tests {
+		got := parseAcceptLanguage(tt.header)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseAcceptLanguage(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	v := New()
+
+	tests := []struct {
+		acceptLanguage string
+		locale         string
+	}{
+		{acceptLanguage: "", locale: "en"},
+		{acceptLanguage: "de", locale: "en"},
+		{acceptLanguage: "fr", locale: "fr"},
+		{acceptLanguage: "pt-BR", locale: "pt"},
+		{acceptLanguage: "es-MX, fr;q=0.9", locale: "es"},
+		{acceptLanguage: "de, fr;q=0.8, en;q=0.9", locale: "en"},
+		{acceptLanguage: "de, fr;q=0.8", locale: "fr"},
+	}
+
+	for _, tt := range tests {
+		got := v.Message(tt.acceptLanguage, MsgNotFound)
+		if want := catalog[tt.locale][MsgNotFound]; got != want {
+			t.Errorf("Message(%q) = %q, want the %s %q", tt.acceptLanguage, got, tt.locale, want)
+		}
+	}
+
+	got := v.Message("fr", MsgMethodNotAllowed, "PATCH")
+	if want := "la méthode PATCH n'est pas prise en charge pour cette ressource"; got != want {
+		t.Errorf("Message with a param = %q, want %q", got, want)
+	}
+}
+
+func TestCatalogIsComplete(t *testing.T) {
+	for locale, messages := range catalog {
+		for key := range catalog["en"] {
+			if messages[key] == "" {
+				t.Errorf("%s has no %s message", locale, key)
+			}
+		}
+	}
+}
+
+func TestStruct(t *testing.T) {
+	v := New()
+
+	type input struct {
+		Comment string `json:"comment" validate:"required,max=5"`
+	}
+
+	tests := []struct {
+		name           string
+		input          input
+		acceptLanguage string
+		want           string
+	}{
+		{name: "valid", input: input{Comment: "hi"}},
+		{name: "english", input: input{}, want: "comment is a required field"},
+		{name: "french", input: input{}, acceptLanguage: "fr", want: "comment est un champ obligatoire"},
+		{name: "too long", input: input{Comment: "a long comment"}, want: "comment must be a maximum of 5 characters in length"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := v.Struct(tt.input, tt.acceptLanguage)
+			if tt.want == "" {
+				if errs != nil {
+					t.Errorf("unexpected errors %v", errs)
+				}
+				return
+			}
+
+			if !strings.EqualFold(errs["comment"], tt.want) {
+				t.Errorf("comment error = %q, want %q", errs["comment"], tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorsCheck(t *testing.T) {
+	errs := Errors{}
+	errs.Check(true, "a", "ignored")
+	errs.Check(false, "b", "first")
+	errs.Check(false, "b", "second")
+
+	if want := (Errors{"b": "first"}); !reflect.DeepEqual(errs, want) {
+		t.Errorf("errors = %v, want %v", errs, want)
+	}
+}
@@ -3,30 +3,102 @@ package data
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
+)
+
+// Comment statuses. Only visible comments are returned by the public
+// queries; the others wait in, or were removed through, moderation.
+const (
+	StatusVisible = "visible"
+	StatusHidden  = "hidden"
+	StatusPending = "pending"
+	StatusDeleted = "deleted"
 )
 
 type Comment struct {
-	ID          int64     `json:"id"`
-	CreatedAt   time.Time `json:"-"`
-	Comment     string    `json:"comment"`
-	Movie       string    `json:"movie_name"`
-	CommenterIp string    `json:"commenter_ip"`
-	Version     int32     `json:"version"`
+	ID          int64          `json:"id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	Comment     string         `json:"comment"`
+	Movie       string         `json:"movie_name"`
+	EpisodeID   *int           `json:"episode_id"`
+	CommenterIp string         `json:"commenter_ip"`
+	Version     int32          `json:"version"`
+	Status      string         `json:"status"`
+	DeletedAt   *time.Time     `json:"deleted_at,omitempty"`
+	ModeratedBy *string        `json:"moderated_by,omitempty"`
+	ParentID    *int64         `json:"parent_id"`
+	Reactions   map[string]int `json:"reactions"`
+	Score       int            `json:"score"`
+}
+
+// CommentNode is a comment with its replies nested underneath it.
+// ReplyCount counts every direct reply, including those cut off by the
+// maximum depth of the tree.
+type CommentNode struct {
+	*Comment
+	ReplyCount int            `json:"reply_count"`
+	Replies    []*CommentNode `json:"replies"`
 }
 
 type CommentModels struct {
 	DB *sql.DB
 }
 
+// CommentMovie is the film a movie's comments are stored against. They are
+// matched by EpisodeID, so they follow a film that SWAPI renames; comments
+// left without an episode id by the backfill fall back to matching Title.
+type CommentMovie struct {
+	EpisodeID int
+	Title     string
+}
+
+const commentColumns = `id, created_at, comment, movie_name, episode_id, commenter_ip, version, status, deleted_at, moderated_by, parent_id`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanComment reads a row selected with commentColumns.
+func scanComment(row rowScanner) (*Comment, error) {
+	var comment Comment
+
+	err := row.Scan(
+		&comment.ID,
+		&comment.CreatedAt,
+		&comment.Comment,
+		&comment.Movie,
+		&comment.EpisodeID,
+		&comment.CommenterIp,
+		&comment.Version,
+		&comment.Status,
+		&comment.DeletedAt,
+		&comment.ModeratedBy,
+		&comment.ParentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &comment, nil
+}
+
 func (c CommentModels) Insert(comment *Comment) error {
 
+	if comment.Status == "" {
+		comment.Status = StatusVisible
+	}
+
 	query := `
-		INSERT INTO comments (comment, movie_name, commenter_ip)
-		VALUES ($1, $2, $3)
+		INSERT INTO comments (comment, movie_name, episode_id, commenter_ip, status, parent_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id, created_at, version`
 
-	args := []interface{}{comment.Comment, comment.Movie, comment.CommenterIp}
+	args := []interface{}{comment.Comment, comment.Movie, comment.EpisodeID, comment.CommenterIp, comment.Status, comment.ParentID}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -34,47 +106,613 @@ func (c CommentModels) Insert(comment *Comment) error {
 	return c.DB.QueryRowContext(ctx, query, args...).Scan(&comment.ID, &comment.CreatedAt, &comment.Version)
 }
 
-func (c CommentModels) GetCommentForMovie(movie_name string) ([]*Comment,  int,  error) {
+func (c CommentModels) Get(movie CommentMovie, id int64) (*Comment, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT ` + commentColumns + ` FROM comments
+		WHERE id = $1 AND (episode_id = $2 OR (episode_id IS NULL AND movie_name = $3)) AND status = 'visible'`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	comment, err := scanComment(c.DB.QueryRowContext(ctx, query, id, movie.EpisodeID, movie.Title))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	err = attachReactions(ctx, c.DB, []*Comment{comment})
+	if err != nil {
+		return nil, err
+	}
+
+	return comment, nil
+}
+
+// Update saves comment's text as long as the stored version still matches
+// comment.Version, then bumps the version.
+func (c CommentModels) Update(comment *Comment) error {
 
 	query := `
-		SELECT COUNT(*) OVER(), id, created_at, comment, movie_name, commenter_ip FROM comments WHERE movie_name = $1
-		GROUP BY id, created_at, comment, movie_name, commenter_ip
-		ORDER BY id DESC`
+		UPDATE comments
+		SET comment = $1, version = version + 1
+		WHERE id = $2 AND version = $3 AND status <> 'deleted'
+		RETURNING version`
+
+	args := []interface{}{comment.Comment, comment.ID, comment.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := c.DB.QueryRowContext(ctx, query, args...).Scan(&comment.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return c.missingOrConflict(ctx, comment.ID, StatusDeleted)
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete soft-deletes the comment as long as its stored version still
+// matches. The row is kept, with status "deleted", for later review.
+func (c CommentModels) Delete(id int64, version int32) error {
+
+	query := `
+		UPDATE comments
+		SET status = 'deleted', deleted_at = NOW(), version = version + 1
+		WHERE id = $1 AND version = $2 AND status <> 'deleted'`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := c.DB.ExecContext(ctx, query, id, version)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return c.missingOrConflict(ctx, id, StatusDeleted)
+	}
+
+	return nil
+}
+
+// GetAny returns a comment whatever its status, for moderators.
+func (c CommentModels) GetAny(id int64) (*Comment, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `SELECT ` + commentColumns + ` FROM comments WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	comment, err := scanComment(c.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return comment, nil
+}
+
+// Moderate moves comment from one of the from statuses to status, recording
+// who made the change. A comment in any other status is an edit conflict.
+func (c CommentModels) Moderate(comment *Comment, from []string, status string, moderator string) error {
+
+	query := `
+		UPDATE comments
+		SET status = $1,
+			moderated_by = $2,
+			deleted_at = CASE WHEN $1 = 'deleted' THEN NOW() ELSE NULL END,
+			version = version + 1
+		WHERE id = $3 AND version = $4 AND status = ANY($5)
+		RETURNING version, deleted_at`
+
+	args := []interface{}{status, moderator, comment.ID, comment.Version, pq.Array(from)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := c.DB.QueryRowContext(ctx, query, args...).Scan(&comment.Version, &comment.DeletedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return c.missingOrConflict(ctx, comment.ID)
+		default:
+			return err
+		}
+	}
+
+	comment.Status = status
+	comment.ModeratedBy = &moderator
+
+	return nil
+}
+
+// ModerationQueue lists the comments in status, oldest first, so
+// moderators work through them in the order they arrived.
+func (c CommentModels) ModerationQueue(status string, limit int) ([]*Comment, error) {
+
+	query := `
+		SELECT ` + commentColumns + ` FROM comments
+		WHERE status = $1
+		ORDER BY created_at ASC, id ASC
+		LIMIT $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := c.DB.QueryContext(ctx, query, status, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	comments := []*Comment{}
+
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		comments = append(comments, comment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// missingOrConflict explains why a versioned write matched no rows: the
+// comment is gone, or someone else changed it first. A comment in one of
+// the gone statuses, such as one already deleted, counts as gone.
+func (c CommentModels) missingOrConflict(ctx context.Context, id int64, gone ...string) error {
+	var exists bool
+
+	query := `SELECT EXISTS(SELECT 1 FROM comments WHERE id = $1 AND NOT status = ANY($2))`
+
+	// a nil slice would be sent as NULL, which matches nothing
+	err := c.DB.QueryRowContext(ctx, query, id, pq.Array(append([]string{}, gone...))).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrRecordNotFound
+	}
+
+	return ErrEditConflict
+}
+
+// CommentFilters selects a page of a movie's comments. BeforeID and AfterID
+// are keyset cursors: the page holds the comments immediately before or
+// after that comment in Sort order.
+type CommentFilters struct {
+	Limit     int
+	BeforeID  int64
+	AfterID   int64
+	Sort      string
+	Since     time.Time
+	RootsOnly bool
+}
+
+// CommentSortSafelist is every value CommentFilters.Sort accepts. "top"
+// ranks by score (up votes minus down votes) and "controversial" by how
+// many votes a comment drew and how evenly they split; both rank highest
+// first and fall back to newest first.
+var CommentSortSafelist = []string{"created_at", "-created_at", "top", "controversial"}
+
+// commentRanks is the SQL ranking each sort orders by ahead of created_at.
+// ups and downs are a comment's vote totals.
+var commentRanks = map[string]string{
+	"created_at":    "0",
+	"-created_at":   "0",
+	"top":           "(ups - downs)::float8",
+	"controversial": "CASE WHEN ups = 0 OR downs = 0 THEN 0 ELSE (ups + downs) * LEAST(ups, downs)::float8 / GREATEST(ups, downs) END",
+}
+
+// CommentCursor is the query parameter and comment id that fetch a
+// neighbouring page, e.g. before_id=42.
+type CommentCursor struct {
+	Param string `json:"param"`
+	ID    int64  `json:"id"`
+}
+
+type CommentMetadata struct {
+	Total      int            `json:"total"`
+	Limit      int            `json:"limit"`
+	Sort       string         `json:"sort"`
+	NextCursor *CommentCursor `json:"next_cursor"`
+	PrevCursor *CommentCursor `json:"prev_cursor"`
+}
+
+func (f CommentFilters) descending() bool {
+	return f.Sort != "created_at"
+}
+
+// cursors returns the parameters that move a page forward (towards the end
+// of the sort order) and backward.
+func (f CommentFilters) cursors() (string, string) {
+	if f.descending() {
+		return "before_id", "after_id"
+	}
+	return "after_id", "before_id"
+}
+
+// cursorID returns the before_id or after_id the page is relative to, or 0
+// for the first page.
+func (f CommentFilters) cursorID() int64 {
+	if f.BeforeID != 0 {
+		return f.BeforeID
+	}
+	return f.AfterID
+}
+
+// GetCommentForMovie returns a page of movie's comments selected by
+// filters. It returns ErrInvalidCursor when the cursor comment doesn't
+// exist or isn't part of the listing.
+func (c CommentModels) GetCommentForMovie(movie CommentMovie, filters CommentFilters) ([]*Comment, CommentMetadata, error) {
+
+	metadata := CommentMetadata{Limit: filters.Limit, Sort: filters.Sort}
+
+	forward, _ := filters.cursors()
+	backward := (forward == "before_id" && filters.AfterID != 0) || (forward == "after_id" && filters.BeforeID != 0)
+
+	// A backward page is read in the opposite direction, so the LIMIT keeps
+	// the comments nearest the cursor, and reversed afterwards.
+	direction := "ASC"
+	if filters.descending() != backward {
+		direction = "DESC"
+	}
+
+	rank, ok := commentRanks[filters.Sort]
+	if !ok {
+		rank = commentRanks["-created_at"]
+	}
+
+	// The cursors compare (rank, created_at, id) against the cursor comment,
+	// so the same keyset works for every sort.
+	query := fmt.Sprintf(`
+		WITH votes AS (
+			SELECT comment_id,
+				COUNT(*) FILTER (WHERE reaction = 'up') AS ups,
+				COUNT(*) FILTER (WHERE reaction = 'down') AS downs
+			FROM comment_reactions
+			WHERE comment_id IN (SELECT id FROM comments WHERE episode_id = $7 OR (episode_id IS NULL AND movie_name = $1))
+			GROUP BY comment_id
+		), ranked AS (
+			SELECT comments.*, %s AS rank FROM (
+				SELECT comments.*, COALESCE(votes.ups, 0) AS ups, COALESCE(votes.downs, 0) AS downs
+				FROM comments LEFT JOIN votes ON votes.comment_id = comments.id
+				WHERE (episode_id = $7 OR (episode_id IS NULL AND movie_name = $1)) AND status = 'visible'
+				AND created_at >= $2
+				AND (NOT $6 OR parent_id IS NULL)
+			) comments
+		)
+		SELECT `+commentColumns+` FROM ranked
+		WHERE ($3::bigint = 0 OR (rank, created_at, id) < (SELECT rank, created_at, id FROM ranked WHERE id = $3))
+		AND ($4::bigint = 0 OR (rank, created_at, id) > (SELECT rank, created_at, id FROM ranked WHERE id = $4))
+		ORDER BY rank %s, created_at %s, id %s
+		LIMIT $5`, rank, direction, direction, direction)
+
+	args := []interface{}{movie.Title, filters.Since, filters.BeforeID, filters.AfterID, filters.Limit + 1, filters.RootsOnly, movie.EpisodeID}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	rows, err := c.DB.QueryContext(ctx, query, movie_name)
+	if id := filters.cursorID(); id != 0 {
+		var exists bool
+
+		err := c.DB.QueryRowContext(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM comments
+				WHERE id = $1 AND (episode_id = $2 OR (episode_id IS NULL AND movie_name = $3))
+				AND status = 'visible' AND created_at >= $4
+				AND (NOT $5 OR parent_id IS NULL)
+			)`, id, movie.EpisodeID, movie.Title, filters.Since, filters.RootsOnly).Scan(&exists)
+		if err != nil {
+			return nil, metadata, err
+		}
+
+		if !exists {
+			return nil, metadata, ErrInvalidCursor
+		}
+	}
+
+	countQuery := `
+		SELECT COUNT(*) FROM comments
+		WHERE (episode_id = $4 OR (episode_id IS NULL AND movie_name = $1)) AND status = 'visible'
+		AND created_at >= $2 AND (NOT $3 OR parent_id IS NULL)`
+
+	err := c.DB.QueryRowContext(ctx, countQuery, movie.Title, filters.Since, filters.RootsOnly, movie.EpisodeID).Scan(&metadata.Total)
 	if err != nil {
-		return nil, 0, err
+		return nil, metadata, err
+	}
+
+	rows, err := c.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, metadata, err
 	}
 
 	defer rows.Close()
 
-	totalRecords := 0
 	comments := []*Comment{}
 
 	for rows.Next() {
 
-		var comment Comment
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, metadata, err
+		}
+
+		comments = append(comments, comment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, metadata, err
+	}
+
+	hasMore := len(comments) > filters.Limit
+	if hasMore {
+		comments = comments[:filters.Limit]
+	}
+
+	if backward {
+		for i, j := 0, len(comments)-1; i < j; i, j = i+1, j-1 {
+			comments[i], comments[j] = comments[j], comments[i]
+		}
+	}
+
+	err = attachReactions(ctx, c.DB, comments)
+	if err != nil {
+		return nil, metadata, err
+	}
+
+	if len(comments) > 0 {
+		forwardParam, backwardParam := filters.cursors()
+		cursorGiven := filters.BeforeID != 0 || filters.AfterID != 0
+
+		if (!backward && hasMore) || backward {
+			metadata.NextCursor = &CommentCursor{Param: forwardParam, ID: comments[len(comments)-1].ID}
+		}
+		if (!backward && cursorGiven) || (backward && hasMore) {
+			metadata.PrevCursor = &CommentCursor{Param: backwardParam, ID: comments[0].ID}
+		}
+	}
+
+	return comments, metadata, nil
+}
+
+// GetThreads returns a page of a movie's top-level comments, selected by
+// filters, with their visible replies nested up to maxDepth levels deep.
+func (c CommentModels) GetThreads(movie CommentMovie, filters CommentFilters, maxDepth int) ([]*CommentNode, CommentMetadata, error) {
+	filters.RootsOnly = true
+
+	roots, metadata, err := c.GetCommentForMovie(movie, filters)
+	if err != nil {
+		return nil, metadata, err
+	}
+
+	nodes := make(map[int64]*CommentNode)
+	ids := []int64{}
+	threads := []*CommentNode{}
+
+	for _, root := range roots {
+		node := &CommentNode{Comment: root, Replies: []*CommentNode{}}
+		nodes[root.ID] = node
+		ids = append(ids, root.ID)
+		threads = append(threads, node)
+	}
+
+	if len(roots) == 0 {
+		return threads, metadata, nil
+	}
+
+	replies, err := c.getReplies(ids, maxDepth)
+	if err != nil {
+		return nil, metadata, err
+	}
 
-		err := rows.Scan(
-			&totalRecords,
-			&comment.ID,
-			&comment.CreatedAt,
-			&comment.Comment,
-			&comment.Movie,
-			&comment.CommenterIp,
+	// replies arrive oldest first, so every parent is placed before its children
+	for _, reply := range replies {
+		node := &CommentNode{Comment: reply, Replies: []*CommentNode{}}
+		nodes[reply.ID] = node
+		ids = append(ids, reply.ID)
+
+		if parent, ok := nodes[*reply.ParentID]; ok {
+			parent.Replies = append(parent.Replies, node)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = attachReactions(ctx, c.DB, replies)
+	if err != nil {
+		return nil, metadata, err
+	}
+
+	counts, err := c.countReplies(ids)
+	if err != nil {
+		return nil, metadata, err
+	}
+
+	for id, node := range nodes {
+		node.ReplyCount = counts[id]
+	}
+
+	return threads, metadata, nil
+}
+
+// getReplies returns the visible descendants of the comments in ids, at
+// most maxDepth levels below them.
+func (c CommentModels) getReplies(ids []int64, maxDepth int) ([]*Comment, error) {
+	if maxDepth < 1 {
+		return []*Comment{}, nil
+	}
+
+	query := `
+		WITH RECURSIVE thread AS (
+			SELECT ` + commentColumns + `, 1 AS depth FROM comments
+			WHERE parent_id = ANY($1) AND status = 'visible'
+			UNION ALL
+			SELECT ` + prefixColumns("c", commentColumns) + `, thread.depth + 1 FROM comments c
+			JOIN thread ON c.parent_id = thread.id
+			WHERE c.status = 'visible' AND thread.depth < $2
 		)
+		SELECT ` + commentColumns + ` FROM thread
+		ORDER BY created_at ASC, id ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
 
+	rows, err := c.DB.QueryContext(ctx, query, pq.Array(ids), maxDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	replies := []*Comment{}
+
+	for rows.Next() {
+		reply, err := scanComment(rows)
 		if err != nil {
-			return nil, 0,  err
+			return nil, err
 		}
 
-		comments = append(comments, &comment)
+		replies = append(replies, reply)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return comments, totalRecords,  nil
+	return replies, nil
 }
 
+// countReplies returns the number of visible direct replies to each of ids.
+func (c CommentModels) countReplies(ids []int64) (map[int64]int, error) {
+
+	query := `
+		SELECT parent_id, COUNT(*) FROM comments
+		WHERE parent_id = ANY($1) AND status = 'visible'
+		GROUP BY parent_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := c.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	counts := make(map[int64]int, len(ids))
+
+	for rows.Next() {
+		var id int64
+		var count int
+
+		err := rows.Scan(&id, &count)
+		if err != nil {
+			return nil, err
+		}
 
+		counts[id] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// prefixColumns qualifies every column in a comma-separated list with
+// table, e.g. "id, comment" becomes "c.id, c.comment".
+func prefixColumns(table string, columns string) string {
+	fields := strings.Split(columns, ",")
+	for i, field := range fields {
+		fields[i] = table + "." + strings.TrimSpace(field)
+	}
+	return strings.Join(fields, ", ")
+}
+
+// CountByMovies returns the number of visible comments on each of movies in
+// a single query, keyed by episode id. Every requested movie is present in
+// the result, with 0 when it has no comments.
+func (c CommentModels) CountByMovies(movies []CommentMovie) (map[int]int, error) {
+
+	query := `
+		SELECT m.episode_id, COUNT(*)
+		FROM unnest($1::bigint[], $2::text[]) AS m (episode_id, title)
+		JOIN comments ON comments.status = 'visible'
+			AND (comments.episode_id = m.episode_id OR (comments.episode_id IS NULL AND comments.movie_name = m.title))
+		GROUP BY m.episode_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	counts := make(map[int]int, len(movies))
+	episodes := make([]int64, 0, len(movies))
+	titles := make([]string, 0, len(movies))
+	for _, movie := range movies {
+		counts[movie.EpisodeID] = 0
+		episodes = append(episodes, int64(movie.EpisodeID))
+		titles = append(titles, movie.Title)
+	}
+
+	rows, err := c.DB.QueryContext(ctx, query, pq.Array(episodes), pq.Array(titles))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var episode int
+		var count int
+
+		err := rows.Scan(&episode, &count)
+		if err != nil {
+			return nil, err
+		}
+
+		counts[episode] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,420 @@
+package data
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+// aNewHope is the film the listing tests read comments for.
+var aNewHope = CommentMovie{EpisodeID: 4, Title: "A New Hope"}
+
+// newMock returns models over a sqlmock database that fails the test when an
+// expected query was not run.
+func newMock(t *testing.T) (Models, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+
+	return CommentFactory(db), mock
+}
+
+func TestCommentFiltersCursors(t *testing.T) {
+	tests := []struct {
+		sort     string
+		forward  string
+		backward string
+	}{
+		{sort: "-created_at", forward: "before_id", backward: "after_id"},
+		{sort: "created_at", forward: "after_id", backward: "before_id"},
+	}
+
+	for _, tt := range tests {
+		forward, backward := CommentFilters{Sort: tt.sort}.cursors()
+		if forward != tt.forward || backward != tt.backward {
+			t.Errorf("cursors() for %q = %s, %s, want %s, %s", tt.sort, forward, backward, tt.forward, tt.backward)
+		}
+	}
+}
+
+func TestGetCommentForMovie(t *testing.T) {
+	t.Run("first page", func(t *testing.T) {
+		models, mock := newMock(t)
+
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*)")).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectQuery(regexp.QuoteMeta("ORDER BY rank DESC, created_at DESC, id DESC")).
+			WithArgs("A New Hope", time.Time{}, int64(0), int64(0), 3, false, 4).
+			WillReturnRows(commentRows(
+				&Comment{ID: 3, Comment: "c", Movie: "A New Hope", CommenterIp: "203.0.113.7", Version: 1, Status: StatusVisible},
+				&Comment{ID: 2, Comment: "b", Movie: "A New Hope", CommenterIp: "203.0.113.7", Version: 1, Status: StatusVisible},
+				&Comment{ID: 1, Comment: "a", Movie: "A New Hope", CommenterIp: "203.0.113.7", Version: 1, Status: StatusVisible},
+			))
+		mock.ExpectQuery(regexp.QuoteMeta("GROUP BY comment_id, reaction")).
+			WithArgs(pq.Array([]int64{3, 2})).
+			WillReturnRows(reactionRows().
+				AddRow(3, "up", 2).
+				AddRow(3, "down", 1).
+				AddRow(3, "heart", 4))
+
+		comments, metadata, err := models.Comments.GetCommentForMovie(aNewHope, CommentFilters{Limit: 2, Sort: "-created_at"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := commentIDs(comments); !reflect.DeepEqual(got, []int64{3, 2}) {
+			t.Errorf("comments %v, want [3 2]", got)
+		}
+		if want := map[string]int{"up": 2, "down": 1, "heart": 4}; comments[0].Score != 1 || !reflect.DeepEqual(comments[0].Reactions, want) {
+			t.Errorf("comment 3 has score %d and reactions %v, want 1 and %v", comments[0].Score, comments[0].Reactions, want)
+		}
+		if comments[1].Score != 0 || len(comments[1].Reactions) != 0 {
+			t.Errorf("comment 2 has score %d and reactions %v, want none", comments[1].Score, comments[1].Reactions)
+		}
+		if metadata.NextCursor == nil || *metadata.NextCursor != (CommentCursor{Param: "before_id", ID: 2}) {
+			t.Errorf("next cursor = %+v, want before_id=2", metadata.NextCursor)
+		}
+		if metadata.PrevCursor != nil {
+			t.Errorf("prev cursor = %+v, want none on the first page", metadata.PrevCursor)
+		}
+	})
+
+	t.Run("backward page", func(t *testing.T) {
+		models, mock := newMock(t)
+
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS")).
+			WithArgs(int64(2), 4, "A New Hope", time.Time{}, false).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*)")).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		// after_id walks back up a newest-first listing, so it's read oldest first.
+		mock.ExpectQuery(regexp.QuoteMeta("ORDER BY rank ASC, created_at ASC, id ASC")).
+			WithArgs("A New Hope", time.Time{}, int64(0), int64(2), 2, false, 4).
+			WillReturnRows(commentRows(
+				&Comment{ID: 3, Comment: "c", Movie: "A New Hope", CommenterIp: "203.0.113.7", Version: 1, Status: StatusVisible},
+			))
+		mock.ExpectQuery(regexp.QuoteMeta("GROUP BY comment_id, reaction")).
+			WillReturnRows(reactionRows())
+
+		comments, metadata, err := models.Comments.GetCommentForMovie(aNewHope, CommentFilters{Limit: 1, AfterID: 2, Sort: "-created_at"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := commentIDs(comments); !reflect.DeepEqual(got, []int64{3}) {
+			t.Errorf("comments %v, want [3]", got)
+		}
+		if metadata.NextCursor == nil || *metadata.NextCursor != (CommentCursor{Param: "before_id", ID: 3}) {
+			t.Errorf("next cursor = %+v, want before_id=3", metadata.NextCursor)
+		}
+		if metadata.PrevCursor != nil {
+			t.Errorf("prev cursor = %+v, want none on the newest page", metadata.PrevCursor)
+		}
+	})
+
+	t.Run("cursor outside the listing", func(t *testing.T) {
+		models, mock := newMock(t)
+
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS")).
+			WithArgs(int64(42), 4, "A New Hope", time.Time{}, false).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		_, _, err := models.Comments.GetCommentForMovie(aNewHope, CommentFilters{Limit: 20, BeforeID: 42, Sort: "-created_at"})
+		if !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("err = %v, want ErrInvalidCursor", err)
+		}
+	})
+}
+
+// reactionRows returns rows of the reaction counts query.
+func reactionRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"comment_id", "reaction", "count"})
+}
+
+// commentRows returns comments as rows selected with commentColumns.
+func commentRows(comments ...*Comment) *sqlmock.Rows {
+	rows := sqlmock.NewRows(strings.Split(commentColumns, ", "))
+
+	for _, c := range comments {
+		var episodeID, deletedAt, moderatedBy, parentID driver.Value
+		if c.EpisodeID != nil {
+			episodeID = int64(*c.EpisodeID)
+		}
+		if c.DeletedAt != nil {
+			deletedAt = *c.DeletedAt
+		}
+		if c.ModeratedBy != nil {
+			moderatedBy = *c.ModeratedBy
+		}
+		if c.ParentID != nil {
+			parentID = *c.ParentID
+		}
+
+		rows.AddRow(c.ID, c.CreatedAt, c.Comment, c.Movie, episodeID, c.CommenterIp, c.Version, c.Status, deletedAt, moderatedBy, parentID)
+	}
+
+	return rows
+}
+
+func TestGetCommentForMovieRanks(t *testing.T) {
+	for _, sort := range []string{"top", "controversial"} {
+		t.Run(sort, func(t *testing.T) {
+			models, mock := newMock(t)
+
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*)")).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+			// both rank highest first, whatever direction created_at would take
+			mock.ExpectQuery(regexp.QuoteMeta(commentRanks[sort]+" AS rank") + ".*" + regexp.QuoteMeta("ORDER BY rank DESC, created_at DESC, id DESC")).
+				WillReturnRows(commentRows())
+
+			_, _, err := models.Comments.GetCommentForMovie(aNewHope, CommentFilters{Limit: 20, Sort: sort})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func commentIDs(comments []*Comment) []int64 {
+	ids := make([]int64, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+	return ids
+}
+
+func TestCountByMovies(t *testing.T) {
+	models, mock := newMock(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM unnest($1::bigint[], $2::text[])")).
+		WithArgs(pq.Array([]int64{4, 5}), pq.Array([]string{"A New Hope", "The Empire Strikes Back"})).
+		WillReturnRows(sqlmock.NewRows([]string{"episode_id", "count"}).
+			AddRow(4, 3))
+
+	counts, err := models.Comments.CountByMovies([]CommentMovie{aNewHope, {EpisodeID: 5, Title: "The Empire Strikes Back"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[int]int{4: 3, 5: 0}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("got %v, want %v", counts, want)
+	}
+}
+
+func TestGetComment(t *testing.T) {
+	models, mock := newMock(t)
+
+	// comments that predate episode ids are matched by title
+	mock.ExpectQuery(regexp.QuoteMeta("episode_id = $2 OR (episode_id IS NULL AND movie_name = $3)")).
+		WithArgs(int64(1), 4, "A New Hope").
+		WillReturnRows(commentRows(
+			&Comment{ID: 1, Comment: "a", Movie: "A New Hope", CommenterIp: "203.0.113.7", Version: 1, Status: StatusVisible},
+		))
+	mock.ExpectQuery(regexp.QuoteMeta("GROUP BY comment_id, reaction")).
+		WillReturnRows(reactionRows())
+
+	comment, err := models.Comments.Get(aNewHope, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if comment.ID != 1 || comment.EpisodeID != nil {
+		t.Errorf("comment = %+v, want comment 1 without an episode id", comment)
+	}
+}
+
+func TestUpdateComment(t *testing.T) {
+	tests := []struct {
+		name   string
+		exists bool
+		want   error
+	}{
+		{name: "changed by someone else", exists: true, want: ErrEditConflict},
+		{name: "deleted meanwhile", exists: false, want: ErrRecordNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			models, mock := newMock(t)
+
+			mock.ExpectQuery(regexp.QuoteMeta("UPDATE comments")).
+				WithArgs("Loved it", int64(1), int32(3)).
+				WillReturnRows(sqlmock.NewRows([]string{"version"}))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS")).
+				WithArgs(int64(1), pq.Array([]string{StatusDeleted})).
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(tt.exists))
+
+			err := models.Comments.Update(&Comment{ID: 1, Comment: "Loved it", Version: 3})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteComment(t *testing.T) {
+	t.Run("visible comment", func(t *testing.T) {
+		models, mock := newMock(t)
+
+		mock.ExpectExec(regexp.QuoteMeta("SET status = 'deleted'")).
+			WithArgs(int64(1), int32(3)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := models.Comments.Delete(1, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("already deleted", func(t *testing.T) {
+		models, mock := newMock(t)
+
+		mock.ExpectExec(regexp.QuoteMeta("SET status = 'deleted'")).
+			WithArgs(int64(1), int32(3)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS")).
+			WithArgs(int64(1), pq.Array([]string{StatusDeleted})).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		err := models.Comments.Delete(1, 3)
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("err = %v, want ErrRecordNotFound", err)
+		}
+	})
+}
+
+func TestModerate(t *testing.T) {
+	from := []string{StatusVisible, StatusPending}
+
+	t.Run("hide", func(t *testing.T) {
+		models, mock := newMock(t)
+
+		mock.ExpectQuery(regexp.QuoteMeta("UPDATE comments")).
+			WithArgs(StatusHidden, "obi-wan", int64(1), int32(3), pq.Array(from)).
+			WillReturnRows(sqlmock.NewRows([]string{"version", "deleted_at"}).AddRow(4, nil))
+
+		comment := &Comment{ID: 1, Version: 3, Status: StatusVisible}
+		err := models.Comments.Moderate(comment, from, StatusHidden, "obi-wan")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if comment.Status != StatusHidden || comment.Version != 4 || comment.ModeratedBy == nil || *comment.ModeratedBy != "obi-wan" {
+			t.Errorf("comment = %+v, want hidden by obi-wan at version 4", comment)
+		}
+	})
+
+	t.Run("not in a from status", func(t *testing.T) {
+		models, mock := newMock(t)
+
+		mock.ExpectQuery(regexp.QuoteMeta("UPDATE comments")).
+			WillReturnRows(sqlmock.NewRows([]string{"version", "deleted_at"}))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS")).
+			WithArgs(int64(1), pq.Array([]string{})).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+		err := models.Comments.Moderate(&Comment{ID: 1, Version: 3, Status: StatusDeleted}, from, StatusHidden, "obi-wan")
+		if !errors.Is(err, ErrEditConflict) {
+			t.Errorf("err = %v, want ErrEditConflict", err)
+		}
+	})
+}
+
+func TestModerationQueue(t *testing.T) {
+	models, mock := newMock(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta("ORDER BY created_at ASC, id ASC")).
+		WithArgs(StatusPending, 10).
+		WillReturnRows(commentRows(
+			&Comment{ID: 1, Comment: "a", Movie: "A New Hope", CommenterIp: "203.0.113.7", Version: 1, Status: StatusPending},
+			&Comment{ID: 2, Comment: "b", Movie: "A New Hope", CommenterIp: "203.0.113.8", Version: 1, Status: StatusPending},
+		))
+
+	comments, err := models.Comments.ModerationQueue(StatusPending, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := commentIDs(comments); !reflect.DeepEqual(got, []int64{1, 2}) {
+		t.Errorf("queue %v, want [1 2]", got)
+	}
+}
+
+func TestGetThreads(t *testing.T) {
+	models, mock := newMock(t)
+
+	one, three := int64(1), int64(3)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*)")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(regexp.QuoteMeta("ORDER BY rank DESC, created_at DESC, id DESC")).
+		WithArgs("A New Hope", time.Time{}, int64(0), int64(0), 21, true, 4).
+		WillReturnRows(commentRows(
+			&Comment{ID: 2, Comment: "b", Movie: "A New Hope", Version: 1, Status: StatusVisible},
+			&Comment{ID: 1, Comment: "a", Movie: "A New Hope", Version: 1, Status: StatusVisible},
+		))
+	mock.ExpectQuery(regexp.QuoteMeta("GROUP BY comment_id, reaction")).
+		WillReturnRows(reactionRows())
+	mock.ExpectQuery(regexp.QuoteMeta("WITH RECURSIVE thread")).
+		WithArgs(pq.Array([]int64{2, 1}), 2).
+		WillReturnRows(commentRows(
+			&Comment{ID: 3, Comment: "c", Movie: "A New Hope", Version: 1, Status: StatusVisible, ParentID: &one},
+			&Comment{ID: 4, Comment: "d", Movie: "A New Hope", Version: 1, Status: StatusVisible, ParentID: &three},
+		))
+	mock.ExpectQuery(regexp.QuoteMeta("GROUP BY comment_id, reaction")).
+		WillReturnRows(reactionRows())
+	mock.ExpectQuery(regexp.QuoteMeta("GROUP BY parent_id")).
+		WillReturnRows(sqlmock.NewRows([]string{"parent_id", "count"}).
+			AddRow(1, 1).
+			AddRow(3, 1).
+			AddRow(4, 2))
+
+	threads, _, err := models.Comments.GetThreads(aNewHope, CommentFilters{Limit: 20, Sort: "-created_at"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(threads) != 2 || threads[0].ID != 2 || threads[1].ID != 1 {
+		t.Fatalf("threads %+v, want roots 2 and 1", threads)
+	}
+	if len(threads[0].Replies) != 0 || threads[0].ReplyCount != 0 {
+		t.Errorf("thread 2 = %+v, want no replies", threads[0])
+	}
+
+	reply := threads[1].Replies
+	if threads[1].ReplyCount != 1 || len(reply) != 1 || reply[0].ID != 3 {
+		t.Fatalf("thread 1 replies %+v, want comment 3", reply)
+	}
+	if len(reply[0].Replies) != 1 || reply[0].Replies[0].ID != 4 {
+		t.Errorf("comment 3 replies %+v, want comment 4", reply[0].Replies)
+	}
+	// comment 4's own replies are past maxDepth, but still counted
+	if got := reply[0].Replies[0]; got.ReplyCount != 2 || len(got.Replies) != 0 {
+		t.Errorf("comment 4 = %+v, want 2 uncollected replies", got)
+	}
+}
+
+func TestPrefixColumns(t *testing.T) {
+	got := prefixColumns("c", "id, created_at,comment")
+	if want := "c.id, c.created_at, c.comment"; got != want {
+		t.Errorf("prefixColumns() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,154 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Film is a SWAPI film in the local catalogue, keyed by its SWAPI id.
+// PersonIDs and PlanetIDs are the SWAPI ids of its cast and planets, as
+// written; Characters and Planets are their SWAPI urls, filled in when
+// reading. Starships, Vehicles and Species are SWAPI urls both ways, since
+// those resources aren't in the catalogue.
+type Film struct {
+	ID           int      `json:"id"`
+	EpisodeID    int      `json:"episode_id"`
+	Title        string   `json:"title"`
+	Slug         string   `json:"slug"`
+	OpeningCrawl string   `json:"opening_crawl"`
+	Director     string   `json:"director"`
+	Producer     string   `json:"producer"`
+	ReleaseDate  string   `json:"release_date"`
+	URL          string   `json:"url"`
+	Starships    []string `json:"starships"`
+	Vehicles     []string `json:"vehicles"`
+	Species      []string `json:"species"`
+	PersonIDs    []int    `json:"-"`
+	PlanetIDs    []int    `json:"-"`
+	Characters   []string `json:"-"`
+	Planets      []string `json:"-"`
+}
+
+type FilmModels struct {
+	DB *sql.DB
+}
+
+// Upsert inserts films, or updates those already stored under the same
+// SWAPI id, and replaces their cast and planets. Ids of people or planets
+// missing from the catalogue are skipped, so sync people and planets first.
+func (m FilmModels) Upsert(films []*Film) error {
+
+	query := `
+		INSERT INTO films (id, episode_id, title, slug, opening_crawl, director, producer, release_date, url, starships, vehicles, species)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8::date, $9, COALESCE($10, '{}'), COALESCE($11, '{}'), COALESCE($12, '{}'))
+		ON CONFLICT (id)
+		DO UPDATE SET episode_id = EXCLUDED.episode_id, title = EXCLUDED.title, slug = EXCLUDED.slug,
+			opening_crawl = EXCLUDED.opening_crawl, director = EXCLUDED.director, producer = EXCLUDED.producer,
+			release_date = EXCLUDED.release_date, url = EXCLUDED.url, starships = EXCLUDED.starships,
+			vehicles = EXCLUDED.vehicles, species = EXCLUDED.species, synced_at = NOW()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, film := range films {
+		args := []interface{}{film.ID, film.EpisodeID, film.Title, film.Slug, film.OpeningCrawl, film.Director, film.Producer, film.ReleaseDate, film.URL,
+			pq.Array(film.Starships), pq.Array(film.Vehicles), pq.Array(film.Species)}
+
+		_, err = tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+
+		err = replaceFilmLinks(ctx, tx, "film_people", "person_id", "people", film.ID, film.PersonIDs)
+		if err != nil {
+			return err
+		}
+
+		err = replaceFilmLinks(ctx, tx, "film_planets", "planet_id", "planets", film.ID, film.PlanetIDs)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// replaceFilmLinks sets the rows of a film's join table to ids, keeping
+// only the ids that exist in the referenced table.
+func replaceFilmLinks(ctx context.Context, tx *sql.Tx, table string, column string, referenced string, filmID int, ids []int) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE film_id = $1`, filmID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO ` + table + ` (film_id, ` + column + `)
+		SELECT $1, id FROM ` + referenced + ` WHERE id = ANY($2)`
+
+	_, err = tx.ExecContext(ctx, query, filmID, pq.Array(ids))
+	return err
+}
+
+// GetAll returns every film in the catalogue, oldest release first, with
+// the urls of its cast, planets, starships, vehicles and species.
+func (m FilmModels) GetAll() ([]*Film, error) {
+
+	query := `
+		SELECT f.id, f.episode_id, f.title, f.slug, f.opening_crawl, f.director, f.producer,
+			to_char(f.release_date, 'YYYY-MM-DD'), f.url, f.starships, f.vehicles, f.species,
+			ARRAY(SELECT p.url FROM film_people fp JOIN people p ON p.id = fp.person_id WHERE fp.film_id = f.id ORDER BY p.id),
+			ARRAY(SELECT pl.url FROM film_planets fp JOIN planets pl ON pl.id = fp.planet_id WHERE fp.film_id = f.id ORDER BY pl.id)
+		FROM films f
+		ORDER BY f.release_date, f.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	films := []*Film{}
+	for rows.Next() {
+		var film Film
+
+		err := rows.Scan(
+			&film.ID,
+			&film.EpisodeID,
+			&film.Title,
+			&film.Slug,
+			&film.OpeningCrawl,
+			&film.Director,
+			&film.Producer,
+			&film.ReleaseDate,
+			&film.URL,
+			pq.Array(&film.Starships),
+			pq.Array(&film.Vehicles),
+			pq.Array(&film.Species),
+			pq.Array(&film.Characters),
+			pq.Array(&film.Planets),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		films = append(films, &film)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return films, nil
+}
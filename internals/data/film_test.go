@@ -0,0 +1,80 @@
+package data
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestFilmUpsert(t *testing.T) {
+	models, mock := newMock(t)
+
+	film := &Film{
+		ID:          1,
+		EpisodeID:   4,
+		Title:       "A New Hope",
+		Slug:        "a-new-hope",
+		ReleaseDate: "1977-05-25",
+		URL:         "https://swapi.dev/api/films/1/",
+		Starships:   []string{"https://swapi.dev/api/starships/2/"},
+		Vehicles:    []string{"https://swapi.dev/api/vehicles/4/"},
+		Species:     []string{"https://swapi.dev/api/species/1/"},
+		PersonIDs:   []int{1, 2},
+		PlanetIDs:   []int{1},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO films")).
+		WithArgs(1, 4, "A New Hope", "a-new-hope", "", "", "", "1977-05-25", "https://swapi.dev/api/films/1/",
+			pq.Array(film.Starships), pq.Array(film.Vehicles), pq.Array(film.Species)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM film_people")).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO film_people")).
+		WithArgs(1, pq.Array([]int{1, 2})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM film_planets")).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO film_planets")).
+		WithArgs(1, pq.Array([]int{1})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := models.Films.Upsert([]*Film{film})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFilmGetAll(t *testing.T) {
+	models, mock := newMock(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM films f")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "episode_id", "title", "slug", "opening_crawl", "director", "producer", "release_date", "url", "starships", "vehicles", "species", "characters", "planets"}).
+			AddRow(1, 4, "A New Hope", "a-new-hope", "", "George Lucas", "", "1977-05-25", "https://swapi.dev/api/films/1/",
+				"{https://swapi.dev/api/starships/2/}", "{}", "{https://swapi.dev/api/species/1/}",
+				"{https://swapi.dev/api/people/1/}", "{https://swapi.dev/api/planets/1/}"))
+
+	films, err := models.Films.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(films) != 1 {
+		t.Fatalf("got %d films, want 1", len(films))
+	}
+
+	film := films[0]
+	if !reflect.DeepEqual(film.Starships, []string{"https://swapi.dev/api/starships/2/"}) || len(film.Vehicles) != 0 ||
+		!reflect.DeepEqual(film.Species, []string{"https://swapi.dev/api/species/1/"}) {
+		t.Errorf("starships %v, vehicles %v, species %v", film.Starships, film.Vehicles, film.Species)
+	}
+	if !reflect.DeepEqual(film.Characters, []string{"https://swapi.dev/api/people/1/"}) || !reflect.DeepEqual(film.Planets, []string{"https://swapi.dev/api/planets/1/"}) {
+		t.Errorf("characters %v, planets %v", film.Characters, film.Planets)
+	}
+}
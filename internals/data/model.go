@@ -11,15 +11,25 @@ import (
 
 var (
 	ErrRecordNotFound = errors.New("record not found")
+	ErrInvalidCursor  = errors.New("cursor is not a comment in this listing")
+	ErrEditConflict   = errors.New("edit conflict")
 )
 
 // Create a models struct which wraps the commentsModel.
 type Models struct {
-	Comments CommentModels
+	Comments  CommentModels
+	Reactions ReactionModels
+	Films     FilmModels
+	People    PeopleModels
+	Planets   PlanetModels
 }
 
 func CommentFactory(db *sql.DB) Models {
 	return Models{
-		Comments: CommentModels{DB: db},
+		Comments:  CommentModels{DB: db},
+		Reactions: ReactionModels{DB: db},
+		Films:     FilmModels{DB: db},
+		People:    PeopleModels{DB: db},
+		Planets:   PlanetModels{DB: db},
 	}
 }
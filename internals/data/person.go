@@ -0,0 +1,301 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Person is a SWAPI character in the local catalogue, keyed by its SWAPI
+// id. The text fields are kept as SWAPI reports them; HeightValue,
+// MassValue and BirthYearValue are their parsed numbers, nil when unknown,
+// with birth years before the Battle of Yavin negative. Homeworld is the
+// name of the homeworld planet, filled in when reading.
+type Person struct {
+	ID             int      `json:"id"`
+	Name           string   `json:"name"`
+	Height         string   `json:"height"`
+	Mass           string   `json:"mass"`
+	HairColor      string   `json:"hair_color"`
+	SkinColor      string   `json:"skin_color"`
+	EyeColor       string   `json:"eye_color"`
+	BirthYear      string   `json:"birth_year"`
+	Gender         string   `json:"gender"`
+	HomeworldID    *int     `json:"homeworld_id"`
+	Homeworld      string   `json:"homeworld"`
+	HeightValue    *float64 `json:"-"`
+	MassValue      *float64 `json:"-"`
+	BirthYearValue *float64 `json:"-"`
+	URL            string   `json:"url"`
+}
+
+type PeopleModels struct {
+	DB *sql.DB
+}
+
+// Upsert inserts people, or updates those already stored under the same
+// SWAPI id. Homeworlds must already be in the catalogue.
+func (m PeopleModels) Upsert(people []*Person) error {
+
+	query := `
+		INSERT INTO people (id, name, height, mass, hair_color, skin_color, eye_color, birth_year, gender,
+			homeworld_id, height_value, mass_value, birth_year_value, url)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		ON CONFLICT (id)
+		DO UPDATE SET name = EXCLUDED.name, height = EXCLUDED.height, mass = EXCLUDED.mass,
+			hair_color = EXCLUDED.hair_color, skin_color = EXCLUDED.skin_color, eye_color = EXCLUDED.eye_color,
+			birth_year = EXCLUDED.birth_year, gender = EXCLUDED.gender, homeworld_id = EXCLUDED.homeworld_id,
+			height_value = EXCLUDED.height_value, mass_value = EXCLUDED.mass_value,
+			birth_year_value = EXCLUDED.birth_year_value, url = EXCLUDED.url, synced_at = NOW()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, person := range people {
+		args := []interface{}{
+			person.ID, person.Name, person.Height, person.Mass, person.HairColor, person.SkinColor, person.EyeColor,
+			person.BirthYear, person.Gender, person.HomeworldID, person.HeightValue, person.MassValue,
+			person.BirthYearValue, person.URL,
+		}
+
+		_, err = tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// PeopleSort orders people by Field, one of the keys of peopleSortColumns.
+type PeopleSort struct {
+	Field string
+	Desc  bool
+}
+
+// PeopleRange keeps people whose number Field compares to Value with Op,
+// one of gt, gte, lt, lte or eq. People with an unknown value never match.
+type PeopleRange struct {
+	Field string
+	Op    string
+	Value float64
+}
+
+// PeopleMatch keeps people whose text Field, split on commas, contains any
+// of the lowercase Values.
+type PeopleMatch struct {
+	Field  string
+	Values []string
+}
+
+// PeopleFilters selects, orders and pages through the catalogue's people.
+// A FilmID keeps only the cast of the film with that SWAPI id. A Limit of
+// zero returns every match.
+type PeopleFilters struct {
+	Sort    []PeopleSort
+	Ranges  []PeopleRange
+	Matches []PeopleMatch
+	FilmID  int
+	Offset  int
+	Limit   int
+}
+
+// PeopleSummary describes every person matching a filter, not just the
+// returned page: how many there are and the spread of the known heights.
+type PeopleSummary struct {
+	Total       int
+	KnownHeight int
+	TotalCm     float64
+	MinCm       float64
+	MaxCm       float64
+	MedianCm    float64
+}
+
+// Text columns sort with the "C" collation, which compares bytes like the
+// api's in-memory sort does. Missing values sort last in both directions.
+var peopleSortColumns = map[string]string{
+	"name":       `p.name COLLATE "C"`,
+	"gender":     `p.gender COLLATE "C"`,
+	"height":     `p.height_value`,
+	"mass":       `p.mass_value`,
+	"birth_year": `p.birth_year_value`,
+	"homeworld":  `NULLIF(NULLIF(pl.name, 'unknown'), '') COLLATE "C"`,
+}
+
+var peopleRangeColumns = map[string]string{
+	"height":     `p.height_value`,
+	"mass":       `p.mass_value`,
+	"birth_year": `p.birth_year_value`,
+}
+
+var peopleRangeOps = map[string]string{
+	"gt":  ">",
+	"gte": ">=",
+	"lt":  "<",
+	"lte": "<=",
+	"eq":  "=",
+}
+
+var peopleMatchColumns = map[string]string{
+	"name":       `p.name`,
+	"gender":     `p.gender`,
+	"eye_color":  `p.eye_color`,
+	"hair_color": `p.hair_color`,
+	"skin_color": `p.skin_color`,
+	"homeworld":  `coalesce(pl.name, '')`,
+}
+
+// where builds the WHERE clause for the filters, numbering its
+// placeholders from 1.
+func (f PeopleFilters) where() (string, []interface{}, error) {
+	conditions := []string{"TRUE"}
+	args := []interface{}{}
+
+	for _, r := range f.Ranges {
+		column, ok := peopleRangeColumns[r.Field]
+		op, okOp := peopleRangeOps[r.Op]
+		if !ok || !okOp {
+			return "", nil, fmt.Errorf("invalid people filter %s %s", r.Field, r.Op)
+		}
+		args = append(args, r.Value)
+		conditions = append(conditions, fmt.Sprintf("%s %s $%d", column, op, len(args)))
+	}
+
+	for _, m := range f.Matches {
+		column, ok := peopleMatchColumns[m.Field]
+		if !ok {
+			return "", nil, fmt.Errorf("invalid people filter %s", m.Field)
+		}
+		args = append(args, pq.Array(m.Values))
+		conditions = append(conditions, fmt.Sprintf(`string_to_array(regexp_replace(lower(btrim(%s)), '\s*,\s*', ',', 'g'), ',') && $%d`, column, len(args)))
+	}
+
+	if f.FilmID != 0 {
+		args = append(args, f.FilmID)
+		conditions = append(conditions, fmt.Sprintf("p.id IN (SELECT person_id FROM film_people WHERE film_id = $%d)", len(args)))
+	}
+
+	return strings.Join(conditions, " AND "), args, nil
+}
+
+func (f PeopleFilters) orderBy() (string, error) {
+	var order []string
+
+	for _, s := range f.Sort {
+		column, ok := peopleSortColumns[s.Field]
+		if !ok {
+			return "", fmt.Errorf("invalid people sort field %s", s.Field)
+		}
+		direction := "ASC"
+		if s.Desc {
+			direction = "DESC"
+		}
+		order = append(order, fmt.Sprintf("%s %s NULLS LAST", column, direction))
+	}
+
+	// people equal on every key keep SWAPI's order
+	order = append(order, "p.id ASC")
+
+	return strings.Join(order, ", "), nil
+}
+
+// GetAll returns the page of people selected by filters, along with a
+// summary of every person that matched.
+func (m PeopleModels) GetAll(filters PeopleFilters) ([]*Person, PeopleSummary, error) {
+	var summary PeopleSummary
+
+	where, args, err := filters.where()
+	if err != nil {
+		return nil, summary, err
+	}
+
+	orderBy, err := filters.orderBy()
+	if err != nil {
+		return nil, summary, err
+	}
+
+	from := `
+		FROM people p
+		LEFT JOIN planets pl ON pl.id = p.homeworld_id
+		WHERE ` + where
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	summaryQuery := `
+		SELECT count(*), count(p.height_value), coalesce(sum(p.height_value), 0),
+			coalesce(min(p.height_value), 0), coalesce(max(p.height_value), 0),
+			coalesce(percentile_cont(0.5) WITHIN GROUP (ORDER BY p.height_value), 0)` + from
+
+	err = m.DB.QueryRowContext(ctx, summaryQuery, args...).Scan(
+		&summary.Total,
+		&summary.KnownHeight,
+		&summary.TotalCm,
+		&summary.MinCm,
+		&summary.MaxCm,
+		&summary.MedianCm,
+	)
+	if err != nil {
+		return nil, summary, err
+	}
+
+	query := `
+		SELECT p.id, p.name, p.height, p.mass, p.hair_color, p.skin_color, p.eye_color, p.birth_year, p.gender,
+			p.homeworld_id, coalesce(pl.name, ''), p.height_value, p.mass_value, p.birth_year_value, p.url` + from + `
+		ORDER BY ` + orderBy
+
+	if filters.Limit > 0 {
+		args = append(args, filters.Limit, filters.Offset)
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, summary, err
+	}
+	defer rows.Close()
+
+	people := []*Person{}
+	for rows.Next() {
+		var person Person
+
+		err := rows.Scan(
+			&person.ID,
+			&person.Name,
+			&person.Height,
+			&person.Mass,
+			&person.HairColor,
+			&person.SkinColor,
+			&person.EyeColor,
+			&person.BirthYear,
+			&person.Gender,
+			&person.HomeworldID,
+			&person.Homeworld,
+			&person.HeightValue,
+			&person.MassValue,
+			&person.BirthYearValue,
+			&person.URL,
+		)
+		if err != nil {
+			return nil, summary, err
+		}
+
+		people = append(people, &person)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, summary, err
+	}
+
+	return people, summary, nil
+}
@@ -0,0 +1,55 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Planet is a SWAPI planet in the local catalogue, keyed by its SWAPI id.
+type Planet struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Climate    string `json:"climate"`
+	Terrain    string `json:"terrain"`
+	Population string `json:"population"`
+	URL        string `json:"url"`
+}
+
+type PlanetModels struct {
+	DB *sql.DB
+}
+
+// syncTimeout bounds a whole catalogue upsert, which runs one statement
+// per row inside a single transaction.
+const syncTimeout = 30 * time.Second
+
+// Upsert inserts planets, or updates those already stored under the same
+// SWAPI id.
+func (m PlanetModels) Upsert(planets []*Planet) error {
+
+	query := `
+		INSERT INTO planets (id, name, climate, terrain, population, url)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (id)
+		DO UPDATE SET name = EXCLUDED.name, climate = EXCLUDED.climate, terrain = EXCLUDED.terrain,
+			population = EXCLUDED.population, url = EXCLUDED.url, synced_at = NOW()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, planet := range planets {
+		_, err = tx.ExecContext(ctx, query, planet.ID, planet.Name, planet.Climate, planet.Terrain, planet.Population, planet.URL)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,114 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ReactionSafelist is the fixed set of reactions a comment accepts. "up" and
+// "down" are votes and make up a comment's score.
+var ReactionSafelist = []string{"up", "down", "heart", "laugh", "wow", "sad", "angry"}
+
+type ReactionModels struct {
+	DB *sql.DB
+}
+
+// Set records reactor's reaction to a comment, replacing any reaction the
+// same reactor left before, since each reactor gets one per comment.
+func (m ReactionModels) Set(commentID int64, reactor string, reaction string) error {
+
+	query := `
+		INSERT INTO comment_reactions (comment_id, reactor, reaction)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (comment_id, reactor)
+		DO UPDATE SET reaction = EXCLUDED.reaction, created_at = NOW()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, commentID, reactor, reaction)
+	return err
+}
+
+// Delete removes reactor's reaction to a comment.
+func (m ReactionModels) Delete(commentID int64, reactor string) error {
+
+	query := `
+		DELETE FROM comment_reactions
+		WHERE comment_id = $1 AND reactor = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, commentID, reactor)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// attachReactions fills in the reaction counts and score of every comment
+// with a single GROUP BY query.
+func attachReactions(ctx context.Context, db *sql.DB, comments []*Comment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*Comment, len(comments))
+	ids := make([]int64, 0, len(comments))
+
+	for _, comment := range comments {
+		comment.Reactions = map[string]int{}
+		comment.Score = 0
+		byID[comment.ID] = comment
+		ids = append(ids, comment.ID)
+	}
+
+	query := `
+		SELECT comment_id, reaction, COUNT(*) FROM comment_reactions
+		WHERE comment_id = ANY($1)
+		GROUP BY comment_id, reaction`
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var reaction string
+		var count int
+
+		err := rows.Scan(&id, &reaction, &count)
+		if err != nil {
+			return err
+		}
+
+		comment := byID[id]
+		comment.Reactions[reaction] = count
+
+		switch reaction {
+		case "up":
+			comment.Score += count
+		case "down":
+			comment.Score -= count
+		}
+	}
+
+	return rows.Err()
+}
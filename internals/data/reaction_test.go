@@ -0,0 +1,48 @@
+package data
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSetReaction(t *testing.T) {
+	models, mock := newMock(t)
+
+	mock.ExpectExec(regexp.QuoteMeta("ON CONFLICT (comment_id, reactor)")).
+		WithArgs(int64(1), "203.0.113.7", "heart").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := models.Reactions.Set(1, "203.0.113.7", "heart")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeleteReaction(t *testing.T) {
+	tests := []struct {
+		name string
+		rows int64
+		want error
+	}{
+		{name: "reacted", rows: 1},
+		{name: "never reacted", rows: 0, want: ErrRecordNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			models, mock := newMock(t)
+
+			mock.ExpectExec(regexp.QuoteMeta("DELETE FROM comment_reactions")).
+				WithArgs(int64(1), "203.0.113.7").
+				WillReturnResult(sqlmock.NewResult(0, tt.rows))
+
+			err := models.Reactions.Delete(1, "203.0.113.7")
+			if !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
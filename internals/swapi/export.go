@@ -0,0 +1,163 @@
+package swapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Export snapshot formats.
+const (
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+)
+
+// Export walks every list page of each resource type through c and writes
+// them to dir in the given format, in the layout NewSnapshot reads,
+// removing a list file left in dir in the other format. The JSON format
+// also writes one file per resource, e.g. films/1.json, so the directory
+// works with NewFake too. It returns how many resources of each type were
+// written.
+func Export(ctx context.Context, c Client, dir string, format string) (map[string]int, error) {
+	if format != FormatJSON && format != FormatNDJSON {
+		return nil, fmt.Errorf("swapi: invalid snapshot format %q, must be %s or %s", format, FormatJSON, FormatNDJSON)
+	}
+
+	counts := map[string]int{}
+
+	for _, resource := range Resources {
+		var pages []Page[json.RawMessage]
+
+		next := DefaultBaseURL + "/" + listPath(resource, 1)
+		for next != "" {
+			var page Page[json.RawMessage]
+			err := c.Get(ctx, next, &page)
+			if err != nil {
+				return nil, fmt.Errorf("swapi: exporting %s: %w", resource, err)
+			}
+			pages = append(pages, page)
+
+			next = ""
+			if page.Next != nil {
+				next = *page.Next
+			}
+		}
+
+		var err error
+		stale := filepath.Join(dir, resource+"."+FormatNDJSON)
+		if format == FormatNDJSON {
+			counts[resource], err = exportNDJSON(dir, resource, pages)
+			stale = filepath.Join(dir, resource+"."+FormatJSON)
+		} else {
+			counts[resource], err = exportJSON(dir, resource, pages)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		err = os.Remove(stale)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	return counts, nil
+}
+
+func exportJSON(dir string, resource string, pages []Page[json.RawMessage]) (int, error) {
+	err := os.MkdirAll(filepath.Join(dir, resource), 0o755)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i, page := range pages {
+		err := writeJSONFile(filepath.Join(dir, fixtureFile(listPath(resource, i+1))), page)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, item := range page.Results {
+			var r struct {
+				URL string `json:"url"`
+			}
+			err := json.Unmarshal(item, &r)
+			if err != nil {
+				return 0, err
+			}
+
+			id, err := ResourceID(r.URL)
+			if err != nil {
+				return 0, err
+			}
+
+			err = writeJSONFile(filepath.Join(dir, resource, strconv.Itoa(id)+".json"), item)
+			if err != nil {
+				return 0, err
+			}
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func exportNDJSON(dir string, resource string, pages []Page[json.RawMessage]) (int, error) {
+	var buf bytes.Buffer
+	count := 0
+
+	for _, page := range pages {
+		for _, item := range page.Results {
+			err := json.Compact(&buf, item)
+			if err != nil {
+				return 0, err
+			}
+			buf.WriteByte('\n')
+			count++
+		}
+	}
+
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, writeFile(filepath.Join(dir, resource+".ndjson"), buf.Bytes())
+}
+
+func writeJSONFile(name string, v interface{}) error {
+	body, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(name, append(body, '\n'))
+}
+
+// writeFile replaces name in one rename, so a snapshot being watched never
+// sees a half-written file.
+func writeFile(name string, body []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(body)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
@@ -0,0 +1,54 @@
+package swapi
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type fileSource struct {
+	dir string
+}
+
+// NewFake returns a Client that serves recorded SWAPI responses from dir
+// instead of the network. A resource path maps onto the directory as
+//
+//	films/            -> films.json
+//	films/1/          -> films/1.json
+//	people/?page=2    -> people/page-2.json
+//
+// and any path without a matching file is reported as ErrNotFound.
+func NewFake(dir string) Client {
+	return &client{src: &fileSource{dir: dir}}
+}
+
+func (s *fileSource) fetch(ctx context.Context, path string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	body, err := os.ReadFile(filepath.Join(s.dir, fixtureFile(path)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	return body, err
+}
+
+// fixtureFile returns the file, relative to a fixture directory, holding
+// the response for a SWAPI resource path.
+func fixtureFile(path string) string {
+	path, rawQuery, _ := strings.Cut(path, "?")
+	name := filepath.FromSlash(strings.Trim(path, "/"))
+
+	query, _ := url.ParseQuery(rawQuery)
+	if page := query.Get("page"); page != "" && page != "1" {
+		return filepath.Join(name, "page-"+page+".json")
+	}
+
+	return name + ".json"
+}
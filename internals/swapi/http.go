@@ -0,0 +1,62 @@
+package swapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type httpSource struct {
+	baseURL string
+	timeout time.Duration
+	client  *http.Client
+}
+
+// New returns a Client that talks to the SWAPI instance at baseURL. Every
+// request is bounded by timeout on top of the caller's context; a zero
+// timeout leaves only the context in charge.
+func New(baseURL string, timeout time.Duration) Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	return &client{src: &httpSource{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		timeout: timeout,
+		client:  &http.Client{},
+	}}
+}
+
+func (s *httpSource) fetch(ctx context.Context, path string) ([]byte, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	url := s.baseURL + "/" + path
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case res.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("swapi: GET %s returned %s", url, res.Status)
+	}
+
+	return io.ReadAll(res.Body)
+}
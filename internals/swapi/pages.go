@@ -0,0 +1,87 @@
+package swapi
+
+import (
+	"context"
+	"sync"
+)
+
+// AllPeople walks every page of the people endpoint. Page one is read
+// first to learn the total count, the remaining pages are then fetched by
+// at most workers concurrent requests.
+func AllPeople(ctx context.Context, c Client, workers int) ([]Person, error) {
+	return allPages(ctx, c.People, workers)
+}
+
+// AllPlanets walks every page of the planets endpoint the same way
+// AllPeople does.
+func AllPlanets(ctx context.Context, c Client, workers int) ([]Planet, error) {
+	return allPages(ctx, c.Planets, workers)
+}
+
+func allPages[T any](ctx context.Context, fetch func(context.Context, int) (Page[T], error), workers int) ([]T, error) {
+	first, err := fetch(ctx, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first.Next == nil || len(first.Results) == 0 {
+		return first.Results, nil
+	}
+
+	pageSize := len(first.Results)
+	pageCount := (first.Count + pageSize - 1) / pageSize
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pages := make([][]T, pageCount)
+	pages[0] = first.Results
+
+	jobs := make(chan int)
+	errs := make(chan error, pageCount)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range jobs {
+				page, err := fetch(ctx, n)
+				if err != nil {
+					errs <- err
+					cancel()
+					continue
+				}
+				pages[n-1] = page.Results
+			}
+		}()
+	}
+
+	for n := 2; n <= pageCount; n++ {
+		select {
+		case jobs <- n:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+
+	if err := <-errs; err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make([]T, 0, first.Count)
+	for _, page := range pages {
+		results = append(results, page...)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,88 @@
+package swapi
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAllPeople(t *testing.T) {
+	c := NewFake(fixtures)
+
+	for _, workers := range []int{0, 1, 4, 10} {
+		people, err := AllPeople(context.Background(), c, workers)
+		if err != nil {
+			t.Fatalf("workers=%d: %v", workers, err)
+		}
+		if len(people) != 27 {
+			t.Fatalf("workers=%d: got %d people, want 27", workers, len(people))
+		}
+
+		// pages are stitched back together in order whatever order they
+		// arrived in
+		if people[0].Name != "Luke Skywalker" || people[10].Name != "Anakin Skywalker" {
+			t.Errorf("workers=%d: people out of order, got %q and %q", workers, people[0].Name, people[10].Name)
+		}
+	}
+}
+
+func TestAllPages(t *testing.T) {
+	errPage := errors.New("page failed")
+
+	next := "next"
+	page := func(n, count, size int) Page[int] {
+		p := Page[int]{Count: count}
+		for i := (n-1)*size + 1; i <= n*size && i <= count; i++ {
+			p.Results = append(p.Results, i)
+		}
+		if n*size < count {
+			p.Next = &next
+		}
+		return p
+	}
+
+	tests := []struct {
+		name    string
+		count   int
+		failOn  int
+		want    int
+		wantErr error
+	}{
+		{name: "single page", count: 7, want: 7},
+		{name: "several pages", count: 35, want: 35},
+		{name: "empty", count: 0, want: 0},
+		{name: "failing page", count: 35, failOn: 3, wantErr: errPage},
+		{name: "failing first page", count: 35, failOn: 1, wantErr: errPage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			fetch := func(ctx context.Context, n int) (Page[int], error) {
+				atomic.AddInt32(&calls, 1)
+				if n == tt.failOn {
+					return Page[int]{}, errPage
+				}
+				return page(n, tt.count, 10), nil
+			}
+
+			got, err := allPages(context.Background(), fetch, 2)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if len(got) != tt.want {
+				t.Fatalf("got %d results, want %d", len(got), tt.want)
+			}
+			for i, v := range got {
+				if v != i+1 {
+					t.Fatalf("result %d is %d, want %d", i, v, i+1)
+				}
+			}
+		})
+	}
+}
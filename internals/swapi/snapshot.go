@@ -0,0 +1,324 @@
+package swapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resources are the SWAPI resource types a snapshot holds.
+var Resources = []string{"films", "people", "planets", "species", "starships", "vehicles"}
+
+// snapshotPageSize is how many resources a snapshot list page holds, the
+// same as SWAPI's.
+const snapshotPageSize = 10
+
+// resourceSet is every resource of one type, in the order the snapshot
+// lists them, indexed by SWAPI id.
+type resourceSet struct {
+	items []json.RawMessage
+	byID  map[int]json.RawMessage
+}
+
+type snapshotSource struct {
+	dir         string
+	mu          sync.RWMutex
+	data        map[string]*resourceSet
+	fingerprint uint64
+}
+
+// Snapshot is a Client that serves a SWAPI dump held in memory, loaded
+// from a directory laid out in either of two ways for each resource type:
+//
+//	films.json, films/page-2.json, ...   the list pages as SWAPI returns them
+//	films.ndjson                         one resource per line
+//
+// A type stored both ways is an error, since there is no telling which is
+// current. Per-id files such as films/1.json may sit alongside the pages,
+// but resources are indexed from the list pages, so they need not. Any
+// list page is served, paged like SWAPI; types missing from the directory
+// are reported as ErrNotFound.
+type Snapshot struct {
+	Client
+	src *snapshotSource
+}
+
+// NewSnapshot loads the snapshot in dir.
+func NewSnapshot(dir string) (*Snapshot, error) {
+	src := &snapshotSource{dir: dir}
+
+	fingerprint, err := snapshotFingerprint(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	src.data, err = loadSnapshot(dir)
+	if err != nil {
+		return nil, err
+	}
+	src.fingerprint = fingerprint
+
+	return &Snapshot{Client: &client{src: src}, src: src}, nil
+}
+
+// Watch polls the snapshot directory every interval until ctx is
+// cancelled, reloading it whenever a file is added, removed or changed.
+// onReload is called after each reload with its error, if any; a snapshot
+// that fails to load leaves the previous one in service.
+func (s *Snapshot) Watch(ctx context.Context, interval time.Duration, onReload func(error)) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		fingerprint, err := snapshotFingerprint(s.src.dir)
+		if err != nil {
+			onReload(err)
+			continue
+		}
+
+		s.src.mu.RLock()
+		unchanged := fingerprint == s.src.fingerprint
+		s.src.mu.RUnlock()
+		if unchanged {
+			continue
+		}
+
+		data, err := loadSnapshot(s.src.dir)
+
+		s.src.mu.Lock()
+		// a half-written snapshot changes again once complete, so remember
+		// this version either way rather than retrying it every tick
+		s.src.fingerprint = fingerprint
+		if err == nil {
+			s.src.data = data
+		}
+		s.src.mu.Unlock()
+
+		onReload(err)
+	}
+}
+
+func (s *snapshotSource) fetch(ctx context.Context, path string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	path, rawQuery, _ := strings.Cut(path, "?")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	s.mu.RLock()
+	set, ok := s.data[parts[0]]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	switch len(parts) {
+	case 1:
+		query, _ := url.ParseQuery(rawQuery)
+		page := 1
+		if query.Get("page") != "" {
+			var err error
+			page, err = strconv.Atoi(query.Get("page"))
+			if err != nil || page < 1 {
+				return nil, ErrNotFound
+			}
+		}
+		return set.page(parts[0], page)
+	case 2:
+		id, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, ErrNotFound
+		}
+		item, ok := set.byID[id]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		return item, nil
+	default:
+		return nil, ErrNotFound
+	}
+}
+
+// page builds list page n of the set the way SWAPI would, with next and
+// previous links pointing at the public api.
+func (set *resourceSet) page(resource string, n int) ([]byte, error) {
+	start := (n - 1) * snapshotPageSize
+	if start > 0 && start >= len(set.items) {
+		return nil, ErrNotFound
+	}
+
+	end := start + snapshotPageSize
+	if end > len(set.items) {
+		end = len(set.items)
+	}
+
+	page := Page[json.RawMessage]{Count: len(set.items), Results: set.items[start:end]}
+	if end < len(set.items) {
+		next := fmt.Sprintf("%s/%s/?page=%d", DefaultBaseURL, resource, n+1)
+		page.Next = &next
+	}
+	if n > 1 {
+		previous := fmt.Sprintf("%s/%s/?page=%d", DefaultBaseURL, resource, n-1)
+		page.Previous = &previous
+	}
+	if page.Results == nil {
+		page.Results = []json.RawMessage{}
+	}
+
+	return json.Marshal(page)
+}
+
+// loadSnapshot reads every resource type present in dir.
+func loadSnapshot(dir string) (map[string]*resourceSet, error) {
+	data := map[string]*resourceSet{}
+
+	for _, resource := range Resources {
+		items, found, err := readResource(dir, resource)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+
+		set := &resourceSet{items: items, byID: make(map[int]json.RawMessage, len(items))}
+		for _, item := range items {
+			var resource struct {
+				URL string `json:"url"`
+			}
+			err := json.Unmarshal(item, &resource)
+			if err != nil {
+				return nil, fmt.Errorf("swapi: snapshot %s: %w", dir, err)
+			}
+
+			id, err := ResourceID(resource.URL)
+			if err != nil {
+				return nil, fmt.Errorf("swapi: snapshot %s: %w", dir, err)
+			}
+			set.byID[id] = item
+		}
+
+		data[resource] = set
+	}
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("swapi: no snapshot in %s, expected files such as films.json or films.ndjson", dir)
+	}
+
+	return data, nil
+}
+
+// readResource reads one resource type from its NDJSON file, or else by
+// walking its JSON list pages.
+func readResource(dir string, resource string) ([]json.RawMessage, bool, error) {
+	body, err := os.ReadFile(filepath.Join(dir, resource+".ndjson"))
+	switch {
+	case err == nil:
+		_, err := os.Stat(filepath.Join(dir, resource+".json"))
+		if err == nil {
+			return nil, false, fmt.Errorf("swapi: snapshot has both %s.ndjson and %s.json, remove one", resource, resource)
+		}
+
+		items, err := parseNDJSON(body)
+		if err != nil {
+			return nil, false, fmt.Errorf("swapi: snapshot %s.ndjson: %w", resource, err)
+		}
+		return items, true, nil
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, false, err
+	}
+
+	files := &fileSource{dir: dir}
+	var items []json.RawMessage
+
+	path := listPath(resource, 1)
+	for {
+		body, err := files.fetch(context.Background(), path)
+		if errors.Is(err, ErrNotFound) && len(items) == 0 {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("swapi: snapshot %s: %w", fixtureFile(path), err)
+		}
+
+		var page Page[json.RawMessage]
+		err = json.Unmarshal(body, &page)
+		if err != nil {
+			return nil, false, fmt.Errorf("swapi: snapshot %s: %w", fixtureFile(path), err)
+		}
+		items = append(items, page.Results...)
+
+		if page.Next == nil {
+			return items, true, nil
+		}
+
+		path, err = ResourcePath(*page.Next)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+}
+
+func parseNDJSON(body []byte) ([]json.RawMessage, error) {
+	var items []json.RawMessage
+
+	for i, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !json.Valid([]byte(line)) {
+			return nil, fmt.Errorf("line %d is not valid JSON", i+1)
+		}
+		items = append(items, json.RawMessage(line))
+	}
+
+	return items, nil
+}
+
+// snapshotFingerprint hashes the name, size and modification time of
+// every snapshot file in dir, which changes whenever one of them does.
+func snapshotFingerprint(dir string) (uint64, error) {
+	h := fnv.New64a()
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := filepath.Ext(path)
+		if d.IsDir() || (ext != ".json" && ext != ".ndjson") {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(h, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+
+	return h.Sum64(), err
+}
@@ -0,0 +1,232 @@
+package swapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotFetch(t *testing.T) {
+	s, err := NewSnapshot(fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     string
+		count    int
+		results  int
+		next     bool
+		previous bool
+		wantErr  error
+	}{
+		{path: "people/", count: 27, results: 10, next: true},
+		{path: "people/?page=2", count: 27, results: 10, next: true, previous: true},
+		{path: "people/?page=3", count: 27, results: 7, previous: true},
+		{path: "people/?page=4", wantErr: ErrNotFound},
+		{path: "people/?page=0", wantErr: ErrNotFound},
+		{path: "people/?page=x", wantErr: ErrNotFound},
+		{path: "films/", count: 6, results: 6},
+		{path: "unknown/", wantErr: ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		var page Page[json.RawMessage]
+		err := s.Get(context.Background(), DefaultBaseURL+"/"+tt.path, &page)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error = %v, want %v", tt.path, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr != nil {
+			continue
+		}
+
+		if page.Count != tt.count || len(page.Results) != tt.results || (page.Next != nil) != tt.next || (page.Previous != nil) != tt.previous {
+			t.Errorf("%s: got count %d, %d results, next %v, previous %v", tt.path, page.Count, len(page.Results), page.Next != nil, page.Previous != nil)
+		}
+	}
+
+	person, err := s.Person(context.Background(), 1)
+	if err != nil || person.Name != "Luke Skywalker" {
+		t.Errorf("person 1 = %q, %v, want Luke Skywalker", person.Name, err)
+	}
+
+	_, err = s.Person(context.Background(), 9999)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing person returned %v, want ErrNotFound", err)
+	}
+}
+
+func TestSnapshotLoadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{name: "empty", files: map[string]string{}, want: "no snapshot"},
+		{
+			name:  "invalid ndjson",
+			files: map[string]string{"films.ndjson": "{\n"},
+			want:  "not valid JSON",
+		},
+		{
+			name:  "resource without an id",
+			files: map[string]string{"films.ndjson": `{"url": "https://swapi.dev/api/films/"}` + "\n"},
+			want:  "no id",
+		},
+		{
+			name: "both formats",
+			files: map[string]string{
+				"films.ndjson": `{"url": "https://swapi.dev/api/films/1/"}` + "\n",
+				"films.json":   `{"count": 0, "results": []}`,
+			},
+			want: "both films.ndjson and films.json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, body := range tt.files {
+				writeTestFile(t, filepath.Join(dir, name), body)
+			}
+
+			_, err := NewSnapshot(dir)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	source, err := NewSnapshot(fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{FormatJSON, FormatNDJSON} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+
+			counts, err := Export(context.Background(), source, dir, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			exported, err := NewSnapshot(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, resource := range Resources {
+				want := len(source.src.data[resource].items)
+				if counts[resource] != want || len(exported.src.data[resource].items) != want {
+					t.Errorf("%s: exported %d and reloaded %d, want %d", resource, counts[resource], len(exported.src.data[resource].items), want)
+				}
+			}
+
+			if format == FormatJSON {
+				_, err := NewFake(dir).Person(context.Background(), 1)
+				if err != nil {
+					t.Errorf("fake over the export: %v", err)
+				}
+			}
+		})
+	}
+
+	t.Run("replaces the other format", func(t *testing.T) {
+		dir := t.TempDir()
+
+		_, err := Export(context.Background(), source, dir, FormatNDJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = Export(context.Background(), source, dir, FormatJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, "films.ndjson")); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("films.ndjson left behind: %v", err)
+		}
+		if _, err := NewSnapshot(dir); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		_, err := Export(context.Background(), source, t.TempDir(), "xml")
+		if err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestSnapshotWatch(t *testing.T) {
+	dir := t.TempDir()
+	films := filepath.Join(dir, "films.ndjson")
+	writeTestFile(t, films, `{"title": "A New Hope", "episode_id": 4, "url": "https://swapi.dev/api/films/1/"}`+"\n")
+
+	s, err := NewSnapshot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reloads := make(chan error)
+	go s.Watch(ctx, 10*time.Millisecond, func(err error) { reloads <- err })
+
+	title := func() string {
+		list, err := s.Films(context.Background())
+		if err != nil || len(list) != 1 {
+			t.Fatalf("films = %v, %v", list, err)
+		}
+		return list[0].Title
+	}
+
+	// a broken snapshot is reported and the previous one kept
+	writeTestFile(t, films, "{\n")
+	if err := <-reloads; err == nil {
+		t.Error("broken snapshot reloaded without an error")
+	}
+	if got := title(); got != "A New Hope" {
+		t.Errorf("after a failed reload the film is %q, want A New Hope", got)
+	}
+
+	writeTestFile(t, films, `{"title": "The Empire Strikes Back", "episode_id": 5, "url": "https://swapi.dev/api/films/2/"}`+"\n")
+	if err := <-reloads; err != nil {
+		t.Fatal(err)
+	}
+	if got := title(); got != "The Empire Strikes Back" {
+		t.Errorf("after a reload the film is %q, want The Empire Strikes Back", got)
+	}
+}
+
+// writeTestFile writes body to name with a modification time that is
+// guaranteed to differ from the file's previous one.
+func writeTestFile(t *testing.T, name string, body string) {
+	t.Helper()
+
+	info, statErr := os.Stat(name)
+
+	err := os.WriteFile(name, []byte(body), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if statErr == nil {
+		modTime := info.ModTime().Add(time.Second)
+		err = os.Chtimes(name, modTime, modTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
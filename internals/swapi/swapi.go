@@ -0,0 +1,268 @@
+package swapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DefaultBaseURL is the public Star Wars API the client talks to when no
+// other base url is configured.
+const DefaultBaseURL = "https://swapi.dev/api"
+
+var (
+	// ErrNotFound is returned when SWAPI (or a fixture directory) has no
+	// resource at the requested path.
+	ErrNotFound = errors.New("swapi: resource not found")
+)
+
+// Page is a single page of a SWAPI list endpoint.
+type Page[T any] struct {
+	Count    int     `json:"count"`
+	Next     *string `json:"next"`
+	Previous *string `json:"previous"`
+	Results  []T     `json:"results"`
+}
+
+type Film struct {
+	Title        string   `json:"title"`
+	EpisodeID    int      `json:"episode_id"`
+	OpeningCrawl string   `json:"opening_crawl"`
+	Director     string   `json:"director"`
+	Producer     string   `json:"producer"`
+	ReleaseDate  string   `json:"release_date"`
+	Characters   []string `json:"characters"`
+	Planets      []string `json:"planets"`
+	Starships    []string `json:"starships"`
+	Vehicles     []string `json:"vehicles"`
+	Species      []string `json:"species"`
+	URL          string   `json:"url"`
+}
+
+type Person struct {
+	Name      string   `json:"name"`
+	Height    string   `json:"height"`
+	Mass      string   `json:"mass"`
+	HairColor string   `json:"hair_color"`
+	SkinColor string   `json:"skin_color"`
+	EyeColor  string   `json:"eye_color"`
+	BirthYear string   `json:"birth_year"`
+	Gender    string   `json:"gender"`
+	Homeworld string   `json:"homeworld"`
+	Films     []string `json:"films"`
+	Species   []string `json:"species"`
+	Vehicles  []string `json:"vehicles"`
+	Starships []string `json:"starships"`
+	URL       string   `json:"url"`
+}
+
+type Planet struct {
+	Name           string   `json:"name"`
+	RotationPeriod string   `json:"rotation_period"`
+	OrbitalPeriod  string   `json:"orbital_period"`
+	Diameter       string   `json:"diameter"`
+	Climate        string   `json:"climate"`
+	Gravity        string   `json:"gravity"`
+	Terrain        string   `json:"terrain"`
+	SurfaceWater   string   `json:"surface_water"`
+	Population     string   `json:"population"`
+	Residents      []string `json:"residents"`
+	Films          []string `json:"films"`
+	URL            string   `json:"url"`
+}
+
+type Starship struct {
+	Name             string   `json:"name"`
+	Model            string   `json:"model"`
+	Manufacturer     string   `json:"manufacturer"`
+	CostInCredits    string   `json:"cost_in_credits"`
+	Length           string   `json:"length"`
+	Crew             string   `json:"crew"`
+	Passengers       string   `json:"passengers"`
+	HyperdriveRating string   `json:"hyperdrive_rating"`
+	StarshipClass    string   `json:"starship_class"`
+	Pilots           []string `json:"pilots"`
+	Films            []string `json:"films"`
+	URL              string   `json:"url"`
+}
+
+type Vehicle struct {
+	Name          string   `json:"name"`
+	Model         string   `json:"model"`
+	Manufacturer  string   `json:"manufacturer"`
+	CostInCredits string   `json:"cost_in_credits"`
+	Length        string   `json:"length"`
+	Crew          string   `json:"crew"`
+	Passengers    string   `json:"passengers"`
+	VehicleClass  string   `json:"vehicle_class"`
+	Pilots        []string `json:"pilots"`
+	Films         []string `json:"films"`
+	URL           string   `json:"url"`
+}
+
+type Species struct {
+	Name            string   `json:"name"`
+	Classification  string   `json:"classification"`
+	Designation     string   `json:"designation"`
+	AverageHeight   string   `json:"average_height"`
+	AverageLifespan string   `json:"average_lifespan"`
+	Language        string   `json:"language"`
+	Homeworld       *string  `json:"homeworld"`
+	People          []string `json:"people"`
+	Films           []string `json:"films"`
+	URL             string   `json:"url"`
+}
+
+// Client is the set of SWAPI lookups the api needs. List methods take a
+// 1-based page number; Get resolves any SWAPI resource url into dst.
+type Client interface {
+	Films(ctx context.Context) ([]Film, error)
+	Film(ctx context.Context, id int) (Film, error)
+	People(ctx context.Context, page int) (Page[Person], error)
+	Person(ctx context.Context, id int) (Person, error)
+	Planets(ctx context.Context, page int) (Page[Planet], error)
+	Planet(ctx context.Context, id int) (Planet, error)
+	Starship(ctx context.Context, id int) (Starship, error)
+	Vehicle(ctx context.Context, id int) (Vehicle, error)
+	Species(ctx context.Context, id int) (Species, error)
+	Get(ctx context.Context, resourceURL string, dst interface{}) error
+}
+
+// source returns the raw JSON body stored under a SWAPI path such as
+// "films/" or "people/?page=2".
+type source interface {
+	fetch(ctx context.Context, path string) ([]byte, error)
+}
+
+type client struct {
+	src source
+}
+
+func (c *client) get(ctx context.Context, path string, dst interface{}) error {
+	body, err := c.src.fetch(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(body, dst)
+	if err != nil {
+		return fmt.Errorf("swapi: decoding %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (c *client) Films(ctx context.Context) ([]Film, error) {
+	var page Page[Film]
+	err := c.get(ctx, "films/", &page)
+	return page.Results, err
+}
+
+func (c *client) Film(ctx context.Context, id int) (Film, error) {
+	var film Film
+	err := c.get(ctx, fmt.Sprintf("films/%d/", id), &film)
+	return film, err
+}
+
+func (c *client) People(ctx context.Context, page int) (Page[Person], error) {
+	var people Page[Person]
+	err := c.get(ctx, listPath("people", page), &people)
+	return people, err
+}
+
+func (c *client) Person(ctx context.Context, id int) (Person, error) {
+	var person Person
+	err := c.get(ctx, fmt.Sprintf("people/%d/", id), &person)
+	return person, err
+}
+
+func (c *client) Planets(ctx context.Context, page int) (Page[Planet], error) {
+	var planets Page[Planet]
+	err := c.get(ctx, listPath("planets", page), &planets)
+	return planets, err
+}
+
+func (c *client) Planet(ctx context.Context, id int) (Planet, error) {
+	var planet Planet
+	err := c.get(ctx, fmt.Sprintf("planets/%d/", id), &planet)
+	return planet, err
+}
+
+func (c *client) Starship(ctx context.Context, id int) (Starship, error) {
+	var starship Starship
+	err := c.get(ctx, fmt.Sprintf("starships/%d/", id), &starship)
+	return starship, err
+}
+
+func (c *client) Vehicle(ctx context.Context, id int) (Vehicle, error) {
+	var vehicle Vehicle
+	err := c.get(ctx, fmt.Sprintf("vehicles/%d/", id), &vehicle)
+	return vehicle, err
+}
+
+func (c *client) Species(ctx context.Context, id int) (Species, error) {
+	var species Species
+	err := c.get(ctx, fmt.Sprintf("species/%d/", id), &species)
+	return species, err
+}
+
+func (c *client) Get(ctx context.Context, resourceURL string, dst interface{}) error {
+	path, err := ResourcePath(resourceURL)
+	if err != nil {
+		return err
+	}
+	return c.get(ctx, path, dst)
+}
+
+func listPath(resource string, page int) string {
+	if page <= 1 {
+		return resource + "/"
+	}
+	return fmt.Sprintf("%s/?page=%d", resource, page)
+}
+
+// ResourcePath strips the host and "/api/" prefix from a SWAPI url, so
+// "https://swapi.dev/api/people/1/" becomes "people/1/". Urls recorded
+// against one host can then be replayed against any configured base url.
+func ResourcePath(resourceURL string) (string, error) {
+	u, err := url.Parse(resourceURL)
+	if err != nil {
+		return "", fmt.Errorf("swapi: invalid resource url %q: %w", resourceURL, err)
+	}
+
+	path := u.Path
+	if i := strings.Index(path, "/api/"); i >= 0 {
+		path = path[i+len("/api/"):]
+	}
+	path = strings.TrimPrefix(path, "/")
+
+	if path == "" {
+		return "", fmt.Errorf("swapi: invalid resource url %q", resourceURL)
+	}
+
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+
+	return path, nil
+}
+
+// ResourceID returns the numeric id at the end of a SWAPI resource url.
+func ResourceID(resourceURL string) (int, error) {
+	path, err := ResourcePath(resourceURL)
+	if err != nil {
+		return 0, err
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	id, err := strconv.Atoi(parts[len(parts)-1])
+	if err != nil {
+		return 0, fmt.Errorf("swapi: no id in resource url %q", resourceURL)
+	}
+
+	return id, nil
+}
@@ -0,0 +1,87 @@
+package swapi
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// fixtures is the recorded SWAPI the tests replay.
+const fixtures = "../../testdata/swapi"
+
+func TestResourcePath(t *testing.T) {
+	tests := []struct {
+		url     string
+		want    string
+		wantErr bool
+	}{
+		{url: "https://swapi.dev/api/people/1/", want: "people/1/"},
+		{url: "https://swapi.dev/api/people/?page=2", want: "people/?page=2"},
+		{url: "http://localhost:8080/api/films/", want: "films/"},
+		{url: "https://example.com/swapi/api/planets/3/", want: "planets/3/"},
+		{url: "people/1/", want: "people/1/"},
+		{url: "https://swapi.dev/api/", wantErr: true},
+		{url: "://bad", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ResourcePath(tt.url)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ResourcePath(%q) error = %v, want error %v", tt.url, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ResourcePath(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestResourceID(t *testing.T) {
+	tests := []struct {
+		url     string
+		want    int
+		wantErr bool
+	}{
+		{url: "https://swapi.dev/api/people/1/", want: 1},
+		{url: "https://swapi.dev/api/planets/61", want: 61},
+		{url: "https://swapi.dev/api/people/", wantErr: true},
+		{url: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ResourceID(tt.url)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ResourceID(%q) error = %v, want error %v", tt.url, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ResourceID(%q) = %d, want %d", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestFake(t *testing.T) {
+	c := NewFake(fixtures)
+	ctx := context.Background()
+
+	films, err := c.Films(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(films) != 6 {
+		t.Errorf("got %d films, want 6", len(films))
+	}
+
+	person, err := c.Person(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if person.Name != "Luke Skywalker" {
+		t.Errorf("person 1 is %q, want Luke Skywalker", person.Name)
+	}
+
+	_, err = c.Person(ctx, 9999)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing person returned %v, want ErrNotFound", err)
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"os"
+
 	"github.com/JacobNewton007/busha-test/api"
 )
 
@@ -8,5 +10,9 @@ import (
 
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "snapshot" {
+		os.Exit(api.RunSnapshot(os.Args[2:]))
+	}
+
 	api.RunApi()
 }
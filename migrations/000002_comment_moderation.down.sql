@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS comments_movie_name_status_idx;
+
+ALTER TABLE comments DROP CONSTRAINT IF EXISTS comments_status_check;
+
+ALTER TABLE comments
+  DROP COLUMN IF EXISTS moderated_by,
+  DROP COLUMN IF EXISTS deleted_at,
+  DROP COLUMN IF EXISTS status;
@@ -0,0 +1,9 @@
+ALTER TABLE comments
+  ADD COLUMN IF NOT EXISTS status varchar NOT NULL DEFAULT 'visible',
+  ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone,
+  ADD COLUMN IF NOT EXISTS moderated_by varchar;
+
+ALTER TABLE comments ADD CONSTRAINT comments_status_check
+  CHECK (status IN ('visible', 'hidden', 'pending', 'deleted'));
+
+CREATE INDEX IF NOT EXISTS comments_movie_name_status_idx ON comments (movie_name, status, created_at, id);
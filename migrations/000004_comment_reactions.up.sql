@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS comment_reactions (
+  id bigserial PRIMARY KEY,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  comment_id bigint NOT NULL REFERENCES comments (id) ON DELETE CASCADE,
+  reactor varchar NOT NULL,
+  reaction varchar NOT NULL CHECK (reaction IN ('up', 'down', 'heart', 'laugh', 'wow', 'sad', 'angry')),
+  UNIQUE (comment_id, reactor)
+);
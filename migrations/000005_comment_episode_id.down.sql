@@ -0,0 +1,4 @@
+-- movie_name values canonicalized by the up migration are left as they are.
+DROP INDEX IF EXISTS comments_episode_id_idx;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS episode_id;
@@ -0,0 +1,25 @@
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS episode_id integer;
+
+-- Backfill the canonical episode id, and the canonical title, for comments
+-- whose movie_name matches a film's title, slug or episode id in any case or
+-- spacing ("a new hope", "A-New-Hope", "4").
+WITH films (episode_id, title) AS (
+  VALUES
+    (1, 'The Phantom Menace'),
+    (2, 'Attack of the Clones'),
+    (3, 'Revenge of the Sith'),
+    (4, 'A New Hope'),
+    (5, 'The Empire Strikes Back'),
+    (6, 'Return of the Jedi')
+)
+UPDATE comments
+SET episode_id = films.episode_id, movie_name = films.title
+FROM films
+WHERE comments.episode_id IS NULL
+  AND (
+    trim(both '-' from regexp_replace(lower(comments.movie_name), '[^a-z0-9]+', '-', 'g'))
+      = trim(both '-' from regexp_replace(lower(films.title), '[^a-z0-9]+', '-', 'g'))
+    OR trim(comments.movie_name) = films.episode_id::text
+  );
+
+CREATE INDEX IF NOT EXISTS comments_episode_id_idx ON comments (episode_id);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS film_planets;
+DROP TABLE IF EXISTS film_people;
+DROP TABLE IF EXISTS films;
+DROP TABLE IF EXISTS people;
+DROP TABLE IF EXISTS planets;
@@ -0,0 +1,63 @@
+-- A local copy of the SWAPI films, people and planets, keyed by their SWAPI
+-- ids. The *_value columns hold the parsed numbers behind SWAPI's strings
+-- ("1,358", "19BBY", "unknown" as NULL) so they can be sorted and filtered
+-- on in SQL.
+-- Starships, vehicles and species aren't copied; films keep their SWAPI
+-- urls so they can still be listed and expanded.
+CREATE TABLE IF NOT EXISTS planets (
+  id integer PRIMARY KEY,
+  name text NOT NULL,
+  climate text NOT NULL DEFAULT '',
+  terrain text NOT NULL DEFAULT '',
+  population text NOT NULL DEFAULT '',
+  url text NOT NULL UNIQUE,
+  synced_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS people (
+  id integer PRIMARY KEY,
+  name text NOT NULL,
+  height text NOT NULL DEFAULT '',
+  mass text NOT NULL DEFAULT '',
+  hair_color text NOT NULL DEFAULT '',
+  skin_color text NOT NULL DEFAULT '',
+  eye_color text NOT NULL DEFAULT '',
+  birth_year text NOT NULL DEFAULT '',
+  gender text NOT NULL DEFAULT '',
+  homeworld_id integer REFERENCES planets (id) ON DELETE SET NULL,
+  height_value numeric,
+  mass_value numeric,
+  birth_year_value numeric,
+  url text NOT NULL UNIQUE,
+  synced_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS people_homeworld_id_idx ON people (homeworld_id);
+
+CREATE TABLE IF NOT EXISTS films (
+  id integer PRIMARY KEY,
+  episode_id integer NOT NULL UNIQUE,
+  title text NOT NULL,
+  slug text NOT NULL UNIQUE,
+  opening_crawl text NOT NULL DEFAULT '',
+  director text NOT NULL DEFAULT '',
+  producer text NOT NULL DEFAULT '',
+  release_date date NOT NULL,
+  starships text[] NOT NULL DEFAULT '{}',
+  vehicles text[] NOT NULL DEFAULT '{}',
+  species text[] NOT NULL DEFAULT '{}',
+  url text NOT NULL UNIQUE,
+  synced_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS film_people (
+  film_id integer NOT NULL REFERENCES films (id) ON DELETE CASCADE,
+  person_id integer NOT NULL REFERENCES people (id) ON DELETE CASCADE,
+  PRIMARY KEY (film_id, person_id)
+);
+
+CREATE TABLE IF NOT EXISTS film_planets (
+  film_id integer NOT NULL REFERENCES films (id) ON DELETE CASCADE,
+  planet_id integer NOT NULL REFERENCES planets (id) ON DELETE CASCADE,
+  PRIMARY KEY (film_id, planet_id)
+);